@@ -0,0 +1,94 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AuditEntry is a write of a pin recorded by the audit log.
+type AuditEntry struct {
+	Time time.Time
+	Pin  string
+	Gpio int
+	// Operation, "set value", "toggle", "pulse", "set direction" or
+	// "group write".
+	Op    string
+	Value bool
+	// Claim owner of the pin at the time, or else its line consumer.
+	Owner string
+}
+
+func (e AuditEntry) String() string {
+	v := 0
+	if e.Value {
+		v = 1
+	}
+	return fmt.Sprintf("%s %s(%d) %s %d by %s",
+		e.Time.Format(time.RFC3339Nano), e.Pin, e.Gpio, e.Op, v, e.Owner)
+}
+
+var audit struct {
+	sync.Mutex
+	ring []AuditEntry
+	// Index of the next entry and whether the ring has wrapped.
+	next    int
+	wrapped bool
+}
+
+// EnableAudit has the writes of every pin, as set values and output
+// directions of this process, recorded in a log of the last n; that
+// discards any earlier log, and 0 disables it, the default.
+func EnableAudit(n int) {
+	audit.Lock()
+	defer audit.Unlock()
+	audit.ring = nil
+	if n > 0 {
+		audit.ring = make([]AuditEntry, n)
+	}
+	audit.next, audit.wrapped = 0, false
+}
+
+// AuditLog returns the last n entries of the audit log, oldest first, or
+// all of them with n <= 0.
+func AuditLog(n int) []AuditEntry {
+	audit.Lock()
+	defer audit.Unlock()
+	l := make([]AuditEntry, 0, len(audit.ring))
+	if audit.wrapped {
+		l = append(l, audit.ring[audit.next:]...)
+	}
+	l = append(l, audit.ring[:audit.next]...)
+	if n > 0 && n < len(l) {
+		l = l[len(l)-n:]
+	}
+	return l
+}
+
+// audit records a write of the pin if the audit log is enabled.
+func (p *Pin) audit(op string, v bool) {
+	audit.Lock()
+	on := audit.ring != nil
+	audit.Unlock()
+	if !on {
+		return
+	}
+	owner := p.Owner()
+	if owner == "" {
+		owner = p.lineConsumer()
+	}
+	e := AuditEntry{time.Now(), p.Name, p.Gpio, op, v, owner}
+	audit.Lock()
+	defer audit.Unlock()
+	if audit.ring == nil {
+		return
+	}
+	audit.ring[audit.next] = e
+	if audit.next++; audit.next == len(audit.ring) {
+		audit.next, audit.wrapped = 0, true
+	}
+}
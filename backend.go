@@ -0,0 +1,101 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Backend carries out pin operations on one kind of gpio implementation,
+// e.g. sysfs, the character device or a test fake. Directions are the
+// sysfs strings: "in", "out", or "low" and "high" for an output with that
+// initial level.
+//
+// Values and levels are logical: backends invert them, or have the kernel
+// do so, for pins that are ActiveLow.
+type Backend interface {
+	Export(p *Pin) error
+	// Unexport releases the pin; the caller has stopped any watch.
+	Unexport(p *Pin) error
+	IsExported(p *Pin) bool
+	Direction(p *Pin) (string, error)
+	SetDirection(p *Pin, dir string) error
+	Read(p *Pin) (bool, error)
+	Write(p *Pin, v bool) error
+	// Watch replaces any previous watch of the pin, closing its channel,
+	// and with EdgeNone just stops watching.
+	Watch(p *Pin, edge Edge) (<-chan Event, error)
+}
+
+// polaritySetter is implemented by backends that need to act on a change
+// of a pin's ActiveLow.
+type polaritySetter interface {
+	setActiveLow(p *Pin) error
+}
+
+// attrsReader is implemented by backends that can read back the edge and
+// polarity a pin's line is configured with, for Refresh.
+type attrsReader interface {
+	edge(p *Pin) (string, error)
+	activeLow(p *Pin) (bool, error)
+}
+
+// Builtin backends.
+var (
+	// Sysfs uses /sys/class/gpio; this is the default.
+	Sysfs Backend = sysfsBackend{}
+	// Chardev uses /dev/gpiochipN line requests, see UseChardev.
+	Chardev Backend = chardevBackend{}
+)
+
+// Package backend of pins without one of their own.
+var backend = Sysfs
+
+// SetBackend selects the backend of pins that don't have one of their own.
+func SetBackend(b Backend) {
+	mu.Lock()
+	backend = b
+	mu.Unlock()
+}
+
+// onChip reports whether the pin's gpio is a global kernel gpio number: it
+// goes by the package backend or a builtin one, rather than being the line
+// of a backend with a numbering of its own, e.g. an Expander.
+func (p *Pin) onChip() bool {
+	switch p.Backend.(type) {
+	case nil, sysfsBackend, chardevBackend:
+		return true
+	}
+	return false
+}
+
+// backendName describes a backend by its String, if it has one, or else its
+// type.
+func backendName(b Backend) string {
+	if s, f := b.(fmt.Stringer); f {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", b)
+}
+
+// sameBackend reports whether a and b are the same backend, without the
+// panic of == on values of a type that isn't comparable.
+func sameBackend(a, b Backend) bool {
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) {
+		return false
+	}
+	return t == nil || t.Comparable() && a == b
+}
+
+func (p *Pin) backend() Backend {
+	if p.Backend != nil {
+		return p.Backend
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return backend
+}
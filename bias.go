@@ -0,0 +1,59 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Bias selects the pull resistor of a line.
+type Bias int
+
+const (
+	// BiasAsIs leaves the line's bias as firmware or another user set it.
+	BiasAsIs Bias = iota
+	BiasPullUp
+	BiasPullDown
+	BiasDisable
+)
+
+var biasNames = []string{
+	BiasAsIs:     "as-is",
+	BiasPullUp:   "pull-up",
+	BiasPullDown: "pull-down",
+	BiasDisable:  "disable",
+}
+
+func (b Bias) String() string {
+	if b >= 0 && int(b) < len(biasNames) {
+		return biasNames[b]
+	}
+	return fmt.Sprintf("Bias(%d)", int(b))
+}
+
+// ErrBiasUnsupported is returned by SetBias for pins of a backend that
+// can't configure bias, such as sysfs.
+var ErrBiasUnsupported = errors.New("backend can't set pin bias")
+
+// biasSetter is implemented by backends that can configure bias.
+type biasSetter interface {
+	setBias(p *Pin) error
+}
+
+// SetBias sets and applies the pin's Bias. Pins of other backends than the
+// character device keep the Bias but return ErrBiasUnsupported.
+func (p *Pin) SetBias(b Bias) (err error) {
+	defer p.record("set bias", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	p.Bias = b
+	if x, f := p.backend().(biasSetter); f {
+		err = x.setBias(p)
+	} else {
+		err = ErrBiasUnsupported
+	}
+	return
+}
@@ -0,0 +1,66 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/platinasystems/fdt"
+)
+
+var boardMu sync.Mutex
+var boards = make(map[string]*PinConfig)
+
+// The compatible string of the board whose profile the pin map has.
+var board string
+
+// RegisterBoard registers the pin table of the board with the given
+// compatible string, e.g. "platina,mk1", usually from an init function.
+// Building the pin map adds the pins of the first registered board among
+// the device tree root's compatible strings to those of the tree, as
+// LoadPinConfig. A later registration replaces an earlier one.
+func RegisterBoard(compatible string, c PinConfig) error {
+	if _, err := c.check(); err != nil {
+		return fmt.Errorf("%s: %v", compatible, err)
+	}
+	c.Pins = append([]PinConfigEntry(nil), c.Pins...)
+	boardMu.Lock()
+	boards[compatible] = &c
+	boardMu.Unlock()
+	return nil
+}
+
+// Board returns the compatible string of the registered board the pin map
+// was built for, or "" if none was.
+func Board() string {
+	gpioInit()
+	return board
+}
+
+// gatherBoard adds the pins of the tree's registered board.
+func gatherBoard(t *fdt.Tree) {
+	b, f := t.RootNode.Properties["compatible"]
+	if !f {
+		return
+	}
+	boardMu.Lock()
+	defer boardMu.Unlock()
+	for _, compatible := range t.PropStringSlice(b) {
+		c, f := boards[compatible]
+		if !f {
+			continue
+		}
+		board = compatible
+		modes, err := c.check()
+		if err == nil {
+			err = c.add(modes)
+		}
+		if err != nil {
+			initError(fmt.Errorf("board %s: %v", compatible, err))
+		}
+		return
+	}
+}
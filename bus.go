@@ -0,0 +1,71 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import "fmt"
+
+// Bus is an unsigned integer of up to 64 pins, least significant bit first,
+// e.g. board-ID straps or a mux select. Values are logical, so pins that
+// are ActiveLow read and write inverted; Invert inverts the bits of pins
+// wired inverted but not described so.
+type Bus struct {
+	Invert uint64
+
+	g *PinGroup
+}
+
+// NewBus opens the bus of the given pins, least significant bit first, as a
+// PinGroup.
+func NewBus(pins ...*Pin) (*Bus, error) {
+	g, err := NewPinGroup(pins...)
+	if err != nil {
+		return nil, err
+	}
+	return &Bus{g: g}, nil
+}
+
+// NewBusByName is NewBus of the pins FindPin finds by name.
+func NewBusByName(names ...string) (*Bus, error) {
+	pins := make([]*Pin, len(names))
+	for i, name := range names {
+		p, err := lookupPin(name)
+		if err != nil {
+			return nil, err
+		}
+		pins[i] = p
+	}
+	return NewBus(pins...)
+}
+
+func (b *Bus) String() string { return b.g.String() }
+
+// Width returns the number of bits of the bus.
+func (b *Bus) Width() int { return len(b.g.Pins) }
+
+// Pins returns the bus's pins, least significant bit first.
+func (b *Bus) Pins() []*Pin { return b.g.Pins }
+
+// Read returns the value of the bus.
+func (b *Bus) Read() (v uint64, err error) {
+	if v, err = b.g.Read(); err != nil {
+		return 0, err
+	}
+	return (v ^ b.Invert) & b.g.mask(), nil
+}
+
+// Write sets the bus to v, which must fit in its width.
+func (b *Bus) Write(v uint64) error {
+	if v&^b.g.mask() != 0 {
+		return fmt.Errorf("%s: %#x wider than %d bits", b, v, b.Width())
+	}
+	return b.g.Write(v ^ b.Invert)
+}
+
+// SetDirection sets every pin of the bus to the given direction, as
+// PinGroup.SetDirection.
+func (b *Bus) SetDirection(dir string) error { return b.g.SetDirection(dir) }
+
+// Close releases the bus's lines.
+func (b *Bus) Close() error { return b.g.Close() }
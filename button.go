@@ -0,0 +1,178 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"time"
+)
+
+// ButtonEventKind is what a button did.
+type ButtonEventKind int
+
+const (
+	ButtonPress ButtonEventKind = iota
+	ButtonRelease
+	// Held down for Button.Hold, after the press and before the release.
+	ButtonLongPress
+	// Pressed again within Button.DoubleClick of a short press's release,
+	// after the second press.
+	ButtonDoubleClick
+)
+
+var buttonEventNames = []string{
+	ButtonPress:       "press",
+	ButtonRelease:     "release",
+	ButtonLongPress:   "long press",
+	ButtonDoubleClick: "double click",
+}
+
+func (k ButtonEventKind) String() string {
+	if k >= 0 && int(k) < len(buttonEventNames) {
+		return buttonEventNames[k]
+	}
+	return fmt.Sprintf("ButtonEventKind(%d)", int(k))
+}
+
+// ButtonEvent is a classified transition of a button.
+type ButtonEvent struct {
+	Kind ButtonEventKind
+	Time time.Time
+}
+
+// Button classifies the debounced transitions of an input, pressed being
+// its logical high, so an active-low button wants ActiveLow. It watches the
+// pin until closed.
+type Button struct {
+	Pin *Pin
+	// How long the input must be steady for a transition, how long a
+	// press lasts to be long, and how soon a second press must follow
+	// for a double click.
+	Debounce, Hold, DoubleClick time.Duration
+
+	c    chan ButtonEvent
+	done chan struct{}
+}
+
+// NewButton starts watching the pin with a 20ms debounce, a one second
+// hold and a 400ms double click.
+func NewButton(p *Pin) (*Button, error) {
+	return NewButtonTimed(p, 20*time.Millisecond, time.Second,
+		400*time.Millisecond)
+}
+
+// NewButtonTimed is NewButton with the given timing.
+func NewButtonTimed(p *Pin, debounce, hold, doubleClick time.Duration) (*Button, error) {
+	w, err := p.Watch(EdgeBoth)
+	if err != nil {
+		return nil, err
+	}
+	pressed, err := p.Value()
+	if err != nil {
+		p.Watch(EdgeNone)
+		return nil, err
+	}
+	b := &Button{Pin: p, Debounce: debounce, Hold: hold,
+		DoubleClick: doubleClick, c: make(chan ButtonEvent, 16),
+		done: make(chan struct{})}
+	go b.run(w, pressed)
+	return b, nil
+}
+
+// Events returns the button's events, closed when the button is.
+func (b *Button) Events() <-chan ButtonEvent { return b.c }
+
+// Close stops watching the pin.
+func (b *Button) Close() error {
+	_, err := b.Pin.Watch(EdgeNone)
+	<-b.done
+	return err
+}
+
+func (b *Button) send(k ButtonEventKind, t time.Time) {
+	select {
+	case b.c <- ButtonEvent{k, t}:
+	default:
+	}
+}
+
+func (b *Button) run(w <-chan Event, pressed bool) {
+	defer close(b.done)
+	defer close(b.c)
+	settle := time.NewTimer(0)
+	<-settle.C
+	hold := time.NewTimer(0)
+	<-hold.C
+	defer settle.Stop()
+	defer hold.Stop()
+	var released time.Time
+	long, clicked := false, false
+	edge := func(v bool, t time.Time) {
+		if v == pressed {
+			return
+		}
+		pressed = v
+		if v {
+			b.send(ButtonPress, t)
+			if !released.IsZero() && t.Sub(released) <= b.DoubleClick &&
+				!clicked {
+				b.send(ButtonDoubleClick, t)
+				clicked = true
+			} else {
+				clicked = false
+			}
+			long = false
+			resetTimer(hold, b.Hold)
+			return
+		}
+		resetTimer(hold, -1)
+		b.send(ButtonRelease, t)
+		if long || clicked {
+			released = time.Time{}
+		} else {
+			released = t
+		}
+	}
+	var last EdgeEvent
+	for {
+		select {
+		case ev, ok := <-w:
+			if !ok {
+				return
+			}
+			if b.Debounce <= 0 {
+				edge(ev.Value, ev.Time)
+				continue
+			}
+			last = ev.EdgeEvent
+			resetTimer(settle, b.Debounce)
+		case <-settle.C:
+			v, err := b.Pin.Value()
+			if err != nil {
+				v = last.Value
+			}
+			edge(v, last.Time)
+		case t := <-hold.C:
+			if pressed {
+				long = true
+				b.send(ButtonLongPress, t)
+			}
+		}
+	}
+}
+
+// resetTimer stops t, dropping any expiry not yet received, and restarts it
+// for d unless that's negative.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	if d >= 0 {
+		t.Reset(d)
+	}
+}
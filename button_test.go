@@ -0,0 +1,80 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+// buttonEvents receives the kinds of n events of the button, failing on a
+// timeout.
+func buttonEvents(t *testing.T, b *gpio.Button, n int) []gpio.ButtonEventKind {
+	t.Helper()
+	var l []gpio.ButtonEventKind
+	for len(l) < n {
+		select {
+		case e, ok := <-b.Events():
+			if !ok {
+				t.Fatalf("button closed after %d of %d events", len(l), n)
+			}
+			l = append(l, e.Kind)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %d of %d events %v", len(l), n, l)
+		}
+	}
+	return l
+}
+
+func TestButtonLongPress(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 1, Name: "reset_button", Backend: be}
+	b, err := gpio.NewButtonTimed(p, 2*time.Millisecond,
+		30*time.Millisecond, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	// A bouncing press is one press.
+	for _, v := range []bool{true, false, true} {
+		be.Inject(1, v)
+	}
+	got := buttonEvents(t, b, 2)
+	be.Inject(1, false)
+	got = append(got, buttonEvents(t, b, 1)...)
+	want := []gpio.ButtonEventKind{gpio.ButtonPress, gpio.ButtonLongPress,
+		gpio.ButtonRelease}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events %v, want %v", got, want)
+	}
+}
+
+func TestButtonDoubleClick(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 1, Name: "button", Backend: be}
+	b, err := gpio.NewButtonTimed(p, 0, time.Second, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []bool{true, false, true, false} {
+		be.Inject(1, v)
+	}
+	got := buttonEvents(t, b, 5)
+	want := []gpio.ButtonEventKind{gpio.ButtonPress, gpio.ButtonRelease,
+		gpio.ButtonPress, gpio.ButtonDoubleClick, gpio.ButtonRelease}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events %v, want %v", got, want)
+	}
+	if err = b.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, ok := <-b.Events(); ok {
+		t.Error("events not closed by Close")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Consumer label of the lines requested through the character device,
+// guarded by mu.
+var consumer = filepath.Base(os.Args[0])
+
+// SetConsumer sets the consumer label, shown by e.g. gpioinfo, of the lines
+// that pins without one of their own request from now on. The default is
+// the program's name.
+func SetConsumer(name string) {
+	mu.Lock()
+	consumer = name
+	mu.Unlock()
+}
+
+// lineConsumer returns the consumer label of the pin's line requests.
+func (p *Pin) lineConsumer() string {
+	if p.Consumer != "" {
+		return p.Consumer
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return consumer
+}
+
+// UseChardev selects whether pins without a backend of their own go through
+// the GPIO character device (/dev/gpiochipN) uAPI v2 instead of the
+// deprecated sysfs interface, for kernels built without CONFIG_GPIO_SYSFS.
+//
+// With the character device a pin's line is requested on its first use and
+// held, as sysfs would keep it exported, for the life of the process.
+func UseChardev(on bool) {
+	if on {
+		SetBackend(Chardev)
+	} else {
+		SetBackend(Sysfs)
+	}
+}
+
+type chardevBackend struct{}
+
+// lineOf maps the pin's global gpio number to its chip's character device
+// and the line's offset on that chip. Without sysfs to list the chips'
+// bases, the bank of GpioBankToBase is that found in the device tree or,
+// failing that, bank gpioN is taken to be /dev/gpiochipN.
+func (p *Pin) lineOf() (dev string, offset int, err error) {
+	for _, c := range Chips() {
+		if c.Contains(p.Gpio) && c.Dev != "" {
+			return prefix + "/dev/" + c.Dev, p.Gpio - c.Base, nil
+		}
+	}
+	bank, base := "", -1
+	regMu.RLock()
+	for b, x := range GpioBankToBase {
+		if x <= p.Gpio && x > base {
+			bank, base = b, x
+		}
+	}
+	d, f := bankDevs[bank]
+	regMu.RUnlock()
+	if bank == "" {
+		err = fmt.Errorf("%s: no gpiochip", p)
+		return
+	}
+	offset = p.Gpio - base
+	if f {
+		dev = prefix + "/dev/" + d
+		return
+	}
+	var n int
+	if _, err = fmt.Sscanf(bank, "gpio%d", &n); err != nil {
+		return
+	}
+	dev = fmt.Sprintf("%s/dev/gpiochip%d", prefix, n)
+	return
+}
+
+// Line is a line of a gpio character device, e.g. /dev/gpiochip0 line 3.
+type Line struct {
+	Dev    string
+	Offset int
+}
+
+// Line returns the character device line of the pin, whatever its backend.
+func (p *Pin) Line() (*Line, error) {
+	dev, offset, err := p.lineOf()
+	if err != nil {
+		return nil, err
+	}
+	return &Line{Dev: dev, Offset: offset}, nil
+}
+
+// LineFlags are the flags of a line's v2 info.
+type LineFlags struct {
+	Used, ActiveLow, Input, Output bool
+	EdgeRising, EdgeFalling        bool
+	OpenDrain, OpenSource          bool
+	PullUp, PullDown, BiasDisabled bool
+	EventRealtime                  bool
+}
+
+// LineInfoV2 is all the kernel reports of a line through uAPI v2: its name,
+// consumer and flags and the attributes of its configuration, with those it
+// didn't report zero.
+type LineInfoV2 struct {
+	Name, Consumer string
+	Offset         int
+	Flags          LineFlags
+	// The debounce period attribute.
+	Debounce time.Duration
+	// The output values attribute, with bit 0 that of the line, if
+	// HasOutputValues.
+	OutputValues    uint64
+	HasOutputValues bool
+}
@@ -0,0 +1,722 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// GPIO character device uAPI v2, from linux/gpio.h.
+const (
+	gpioMaxNameSize      = 32
+	gpioV2LinesMax       = 64
+	gpioV2LineNumAttrMax = 10
+
+	gpioV2LineFlagUsed          = 1 << 0
+	gpioV2LineFlagActiveLow     = 1 << 1
+	gpioV2LineFlagInput         = 1 << 2
+	gpioV2LineFlagOutput        = 1 << 3
+	gpioV2LineFlagEdgeRising    = 1 << 4
+	gpioV2LineFlagEdgeFalling   = 1 << 5
+	gpioV2LineFlagOpenDrain     = 1 << 6
+	gpioV2LineFlagOpenSource    = 1 << 7
+	gpioV2LineFlagBiasPullUp    = 1 << 8
+	gpioV2LineFlagBiasPullDown  = 1 << 9
+	gpioV2LineFlagBiasDisabled  = 1 << 10
+	gpioV2LineFlagEventRealtime = 1 << 11
+
+	gpioV2LineAttrIdFlags        = 1
+	gpioV2LineAttrIdOutputValues = 2
+	gpioV2LineAttrIdDebounce     = 3
+
+	gpioV2LineEventRisingEdge  = 1
+	gpioV2LineEventFallingEdge = 2
+)
+
+type gpiochipInfo struct {
+	name, label [gpioMaxNameSize]byte
+	lines       uint32
+}
+
+type gpioV2LineAttribute struct {
+	id, padding uint32
+	// Union of flags, output values and debounce period in µs.
+	value uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [gpioV2LineNumAttrMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [gpioV2LinesMax]uint32
+	consumer        [gpioMaxNameSize]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineInfo struct {
+	name, consumer   [gpioMaxNameSize]byte
+	offset, numAttrs uint32
+	flags            uint64
+	attrs            [gpioV2LineNumAttrMax]gpioV2LineAttribute
+	padding          [4]uint32
+}
+
+type gpioV2LineValues struct {
+	bits, mask uint64
+}
+
+type gpioV2LineEvent struct {
+	timestamp                    uint64
+	id, offset, seqno, lineSeqno uint32
+	padding                      [6]uint32
+}
+
+func gpioIoc(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | 0xb4<<8 | nr
+}
+
+var (
+	gpioGetChipInfoIoctl = gpioIoc(2, 0x01,
+		unsafe.Sizeof(gpiochipInfo{}))
+	gpioV2GetLineInfoIoctl = gpioIoc(3, 0x05,
+		unsafe.Sizeof(gpioV2LineInfo{}))
+	gpioV2GetLineIoctl = gpioIoc(3, 0x07,
+		unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineSetConfigIoctl = gpioIoc(3, 0x0d,
+		unsafe.Sizeof(gpioV2LineConfig{}))
+	gpioV2LineGetValuesIoctl = gpioIoc(3, 0x0e,
+		unsafe.Sizeof(gpioV2LineValues{}))
+	gpioV2LineSetValuesIoctl = gpioIoc(3, 0x0f,
+		unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg))
+	if e != 0 {
+		return &os.SyscallError{Syscall: "ioctl", Err: e}
+	}
+	return nil
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// cdevLineInfo returns the kernel's view of the pin's line.
+func (p *Pin) cdevLineInfo() (info gpioV2LineInfo, err error) {
+	dev, offset, err := p.lineOf()
+	if err != nil {
+		return
+	}
+	return (&Line{Dev: dev, Offset: offset}).info()
+}
+
+func (l *Line) info() (info gpioV2LineInfo, err error) {
+	f, err := os.Open(l.Dev)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info.offset = uint32(l.Offset)
+	err = ioctl(f, gpioV2GetLineInfoIoctl, unsafe.Pointer(&info))
+	return
+}
+
+// Info returns the line's v2 info, as from the GET_LINEINFO_V2 ioctl.
+func (l *Line) Info() (LineInfoV2, error) {
+	info, err := l.info()
+	if err != nil {
+		return LineInfoV2{}, fmt.Errorf("%s line %d: %w", l.Dev, l.Offset,
+			err)
+	}
+	return lineInfoV2(&info), nil
+}
+
+// lineInfoV2 maps the kernel's line info to its typed form.
+func lineInfoV2(info *gpioV2LineInfo) (i LineInfoV2) {
+	i.Name = cstring(info.name[:])
+	i.Consumer = cstring(info.consumer[:])
+	i.Offset = int(info.offset)
+	i.Flags = lineFlagsOf(info.flags)
+	n := int(info.numAttrs)
+	if n > len(info.attrs) {
+		n = len(info.attrs)
+	}
+	for _, a := range info.attrs[:n] {
+		switch a.id {
+		case gpioV2LineAttrIdOutputValues:
+			i.OutputValues, i.HasOutputValues = a.value, true
+		case gpioV2LineAttrIdDebounce:
+			i.Debounce = time.Duration(a.value) * time.Microsecond
+		}
+	}
+	return
+}
+
+func lineFlagsOf(flags uint64) LineFlags {
+	is := func(f uint64) bool { return flags&f != 0 }
+	return LineFlags{
+		Used:          is(gpioV2LineFlagUsed),
+		ActiveLow:     is(gpioV2LineFlagActiveLow),
+		Input:         is(gpioV2LineFlagInput),
+		Output:        is(gpioV2LineFlagOutput),
+		EdgeRising:    is(gpioV2LineFlagEdgeRising),
+		EdgeFalling:   is(gpioV2LineFlagEdgeFalling),
+		OpenDrain:     is(gpioV2LineFlagOpenDrain),
+		OpenSource:    is(gpioV2LineFlagOpenSource),
+		PullUp:        is(gpioV2LineFlagBiasPullUp),
+		PullDown:      is(gpioV2LineFlagBiasPullDown),
+		BiasDisabled:  is(gpioV2LineFlagBiasDisabled),
+		EventRealtime: is(gpioV2LineFlagEventRealtime),
+	}
+}
+
+// cdevLineNames returns the names of the lines of the chip device.
+func cdevLineNames(dev string) (names []string, err error) {
+	f, err := os.Open(dev)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var chip gpiochipInfo
+	if err = ioctl(f, gpioGetChipInfoIoctl, unsafe.Pointer(&chip)); err != nil {
+		return
+	}
+	names = make([]string, chip.lines)
+	for i := range names {
+		info := gpioV2LineInfo{offset: uint32(i)}
+		err = ioctl(f, gpioV2GetLineInfoIoctl, unsafe.Pointer(&info))
+		if err != nil {
+			return nil, err
+		}
+		names[i] = cstring(info.name[:])
+	}
+	return
+}
+
+// cdevConfig makes the line configuration of the given flags and, for
+// outputs, value.
+func cdevConfig(flags uint64, v bool) (c gpioV2LineConfig) {
+	c.flags = flags
+	if flags&gpioV2LineFlagOutput != 0 {
+		c.numAttrs = 1
+		c.attrs[0].attr.id = gpioV2LineAttrIdOutputValues
+		if v {
+			c.attrs[0].attr.value = 1
+		}
+		c.attrs[0].mask = 1
+	}
+	return
+}
+
+// cdevRequest requests the pin's line with the given flags and output
+// value, or reconfigures it if the pin already holds the line.
+func (p *Pin) cdevRequest(flags uint64, v bool) (err error) {
+	if p.ActiveLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+	// The kernel only takes a bias along with a direction, and a drive
+	// with output.
+	if flags&(gpioV2LineFlagInput|gpioV2LineFlagOutput) != 0 {
+		flags |= cdevBiasFlags[p.Bias]
+	}
+	if flags&gpioV2LineFlagOutput != 0 {
+		flags |= cdevDriveFlags[p.Drive]
+	}
+	mu.Lock()
+	line := p.line
+	mu.Unlock()
+	config := cdevConfig(flags, v)
+	if flags&gpioV2LineFlagInput != 0 && p.Debounce > 0 {
+		a := &config.attrs[config.numAttrs]
+		a.attr.id = gpioV2LineAttrIdDebounce
+		a.attr.value = uint64(p.Debounce / time.Microsecond)
+		a.mask = 1
+		config.numAttrs++
+	}
+	if line != nil {
+		err = ioctl(line, gpioV2LineSetConfigIoctl, unsafe.Pointer(&config))
+		if err == nil {
+			mu.Lock()
+			p.lineFlags = flags
+			mu.Unlock()
+		}
+		return
+	}
+	dev, offset, err := p.lineOf()
+	if err != nil {
+		return
+	}
+	f, err := os.Open(dev)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var req gpioV2LineRequest
+	req.offsets[0] = uint32(offset)
+	req.numLines = 1
+	copy(req.consumer[:gpioMaxNameSize-1], p.lineConsumer())
+	req.config = config
+	if err = ioctl(f, gpioV2GetLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return
+	}
+	mu.Lock()
+	p.line = os.NewFile(uintptr(req.fd), dev)
+	p.lineFlags = flags
+	mu.Unlock()
+	return
+}
+
+// cdevHeld returns the pin's line request, requesting the line as is if
+// the pin doesn't hold it yet.
+func (p *Pin) cdevHeld() (*os.File, error) {
+	mu.Lock()
+	line := p.line
+	mu.Unlock()
+	if line != nil {
+		return line, nil
+	}
+	if err := p.cdevRequest(0, false); err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return p.line, nil
+}
+
+// Line flags of each Bias.
+var cdevBiasFlags = map[Bias]uint64{
+	BiasPullUp:   gpioV2LineFlagBiasPullUp,
+	BiasPullDown: gpioV2LineFlagBiasPullDown,
+	BiasDisable:  gpioV2LineFlagBiasDisabled,
+}
+
+// Line flags of each Drive.
+var cdevDriveFlags = map[Drive]uint64{
+	DriveOpenDrain:  gpioV2LineFlagOpenDrain,
+	DriveOpenSource: gpioV2LineFlagOpenSource,
+}
+
+// Flags cdevRequest derives from the pin rather than its arguments.
+const cdevPinFlags = gpioV2LineFlagActiveLow | gpioV2LineFlagBiasPullUp |
+	gpioV2LineFlagBiasPullDown | gpioV2LineFlagBiasDisabled |
+	gpioV2LineFlagOpenDrain | gpioV2LineFlagOpenSource
+
+// cdevReapply reconfigures the pin's line for a change of the pin's
+// settings, keeping its direction, edges and the level of an output.
+func (p *Pin) cdevReapply() error {
+	line, err := p.cdevHeld()
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	flags := p.lineFlags
+	mu.Unlock()
+	if flags&(gpioV2LineFlagInput|gpioV2LineFlagOutput) == 0 {
+		info, err := p.cdevLineInfo()
+		if err != nil {
+			return err
+		}
+		if info.flags&gpioV2LineFlagOutput != 0 {
+			flags |= gpioV2LineFlagOutput
+		} else {
+			flags |= gpioV2LineFlagInput
+		}
+	}
+	v := false
+	if flags&gpioV2LineFlagOutput != 0 {
+		values := gpioV2LineValues{mask: 1}
+		err := ioctl(line, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values))
+		if err != nil {
+			return err
+		}
+		v = values.bits&1 != 0
+		if flags&gpioV2LineFlagActiveLow != 0 != p.ActiveLow {
+			v = !v
+		}
+	}
+	return p.cdevRequest(flags&^cdevPinFlags, v)
+}
+
+// setActiveLow reconfigures a held line; others get the polarity when
+// requested.
+func (chardevBackend) setActiveLow(p *Pin) error {
+	mu.Lock()
+	line, flags := p.line, p.lineFlags
+	mu.Unlock()
+	if line == nil || flags&gpioV2LineFlagActiveLow != 0 == p.ActiveLow {
+		return nil
+	}
+	return p.cdevReapply()
+}
+
+func (chardevBackend) setBias(p *Pin) error {
+	return p.cdevReapply()
+}
+
+func (chardevBackend) setDebounce(p *Pin) error {
+	mu.Lock()
+	line, flags := p.line, p.lineFlags
+	mu.Unlock()
+	if line == nil || flags&gpioV2LineFlagInput == 0 {
+		return nil
+	}
+	return p.cdevReapply()
+}
+
+func (chardevBackend) setDrive(p *Pin) error {
+	mu.Lock()
+	line, flags := p.line, p.lineFlags
+	mu.Unlock()
+	if line == nil || flags&gpioV2LineFlagOutput == 0 {
+		return nil
+	}
+	return p.cdevReapply()
+}
+
+func (chardevBackend) Export(p *Pin) error {
+	_, err := p.cdevHeld()
+	return err
+}
+
+func (chardevBackend) Unexport(p *Pin) (err error) {
+	mu.Lock()
+	line := p.line
+	p.line, p.lineFlags = nil, 0
+	mu.Unlock()
+	if line != nil {
+		err = line.Close()
+	}
+	return
+}
+
+func (chardevBackend) IsExported(p *Pin) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return p.line != nil
+}
+
+func (chardevBackend) Direction(p *Pin) (dir string, err error) {
+	info, err := p.cdevLineInfo()
+	if err != nil {
+		return
+	}
+	dir = "in"
+	if info.flags&gpioV2LineFlagOutput != 0 {
+		dir = "out"
+	}
+	return
+}
+
+func (chardevBackend) edge(p *Pin) (edge string, err error) {
+	info, err := p.cdevLineInfo()
+	if err != nil {
+		return
+	}
+	switch info.flags & (gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling) {
+	case gpioV2LineFlagEdgeRising:
+		edge = "rising"
+	case gpioV2LineFlagEdgeFalling:
+		edge = "falling"
+	case gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling:
+		edge = "both"
+	default:
+		edge = "none"
+	}
+	return
+}
+
+func (chardevBackend) activeLow(p *Pin) (on bool, err error) {
+	info, err := p.cdevLineInfo()
+	return info.flags&gpioV2LineFlagActiveLow != 0, err
+}
+
+func (chardevBackend) SetDirection(p *Pin, dir string) error {
+	switch dir {
+	case "in":
+		return p.cdevRequest(gpioV2LineFlagInput, false)
+	case "out", "low":
+		return p.cdevRequest(gpioV2LineFlagOutput, false)
+	case "high":
+		return p.cdevRequest(gpioV2LineFlagOutput, true)
+	}
+	return syscall.EINVAL
+}
+
+func (chardevBackend) Write(p *Pin, v bool) error {
+	mu.Lock()
+	line, flags := p.line, p.lineFlags
+	mu.Unlock()
+	if line == nil || flags&gpioV2LineFlagOutput == 0 {
+		return p.cdevRequest(gpioV2LineFlagOutput, v)
+	}
+	values := gpioV2LineValues{mask: 1}
+	if v {
+		values.bits = 1
+	}
+	return ioctl(line, gpioV2LineSetValuesIoctl, unsafe.Pointer(&values))
+}
+
+func (chardevBackend) Read(p *Pin) (v bool, err error) {
+	line, err := p.cdevHeld()
+	if err != nil {
+		return
+	}
+	values := gpioV2LineValues{mask: 1}
+	err = ioctl(line, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values))
+	v = values.bits&1 != 0
+	return
+}
+
+func (b chardevBackend) Watch(p *Pin, edge Edge) (<-chan Event, error) {
+	return watchArmed(b, p, edge)
+}
+
+func (chardevBackend) armEdge(p *Pin, edge Edge) (src *edgeSource, err error) {
+	p.stopWatch()
+	flags := uint64(gpioV2LineFlagInput)
+	switch edge {
+	case EdgeRising:
+		flags |= gpioV2LineFlagEdgeRising
+	case EdgeFalling:
+		flags |= gpioV2LineFlagEdgeFalling
+	case EdgeBoth:
+		flags |= gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	}
+	if err = p.cdevRequest(flags, false); err != nil || edge == EdgeNone {
+		return
+	}
+	mu.Lock()
+	fd := int(p.line.Fd())
+	mu.Unlock()
+	var buf [16]gpioV2LineEvent
+	size := int(unsafe.Sizeof(buf[0]))
+	b := (*[unsafe.Sizeof(buf)]byte)(unsafe.Pointer(&buf))[:]
+	src = &edgeSource{
+		fd:     fd,
+		events: pollReadable,
+		read: func() ([]EdgeEvent, error) {
+			n, err := syscall.Read(fd, b)
+			if err != nil {
+				return nil, err
+			}
+			events := make([]EdgeEvent, n/size)
+			for i := range events {
+				rising := buf[i].id == gpioV2LineEventRisingEdge
+				events[i] = EdgeEvent{
+					Rising: rising,
+					Value:  rising,
+					Time:   monotonicTime(buf[i].timestamp),
+				}
+			}
+			return events, nil
+		},
+		cleanup: func() {},
+	}
+	return
+}
+
+// monotonicTime converts a CLOCK_MONOTONIC event timestamp to wall time.
+func monotonicTime(ns uint64) time.Time {
+	var ts syscall.Timespec
+	syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1, // CLOCK_MONOTONIC
+		uintptr(unsafe.Pointer(&ts)), 0)
+	return time.Now().Add(time.Duration(int64(ns) - ts.Nano()))
+}
+
+// cdevGroup is a PinGroup's line requests, one per chip; bit j of request
+// i is the group's bit index[i][j].
+type cdevGroup struct {
+	lines []*os.File
+	index [][]int
+	// Lines' polarity and drive flags.
+	flags [][]uint64
+}
+
+// config makes the configuration of request x for the given flags and,
+// for outputs, value of every line.
+func (g *cdevGroup) config(x int, flags uint64, v bool) (c gpioV2LineConfig) {
+	all := ^uint64(0) >> uint(64-len(g.index[x]))
+	c.flags = flags
+	if flags&gpioV2LineFlagOutput != 0 {
+		a := &c.attrs[c.numAttrs]
+		a.attr.id = gpioV2LineAttrIdOutputValues
+		if v {
+			a.attr.value = all
+		}
+		a.mask = all
+		c.numAttrs++
+	}
+	// Lines alike but for the group's flags share an attribute.
+	var sets []uint64
+	masks := make(map[uint64]uint64)
+	for j, lf := range g.flags[x] {
+		if flags&gpioV2LineFlagOutput == 0 {
+			lf &= gpioV2LineFlagActiveLow
+		}
+		if lf == 0 {
+			continue
+		}
+		if _, f := masks[lf]; !f {
+			sets = append(sets, lf)
+		}
+		masks[lf] |= 1 << uint(j)
+	}
+	for _, lf := range sets {
+		a := &c.attrs[c.numAttrs]
+		a.attr.id = gpioV2LineAttrIdFlags
+		a.attr.value = flags | lf
+		a.mask = masks[lf]
+		c.numAttrs++
+	}
+	return
+}
+
+func (chardevBackend) openGroup(pins []*Pin) (lineGroup, error) {
+	g := new(cdevGroup)
+	devs := make(map[string]int)
+	var reqs []gpioV2LineRequest
+	var names []string
+	for i, p := range pins {
+		dev, offset, err := p.lineOf()
+		if err != nil {
+			return nil, err
+		}
+		x, f := devs[dev]
+		if !f {
+			x = len(reqs)
+			devs[dev] = x
+			reqs = append(reqs, gpioV2LineRequest{})
+			copy(reqs[x].consumer[:gpioMaxNameSize-1], p.lineConsumer())
+			names = append(names, dev)
+			g.index = append(g.index, nil)
+			g.flags = append(g.flags, nil)
+		}
+		lf := cdevDriveFlags[p.Drive]
+		if p.ActiveLow {
+			lf |= gpioV2LineFlagActiveLow
+		}
+		g.flags[x] = append(g.flags[x], lf)
+		reqs[x].offsets[reqs[x].numLines] = uint32(offset)
+		reqs[x].numLines++
+		g.index[x] = append(g.index[x], i)
+	}
+	// Release the pins' own lines so the group can request them.
+	for _, p := range pins {
+		p.stopWatch()
+		mu.Lock()
+		line := p.line
+		p.line, p.lineFlags = nil, 0
+		mu.Unlock()
+		if line != nil {
+			line.Close()
+		}
+	}
+	for x := range reqs {
+		reqs[x].config = g.config(x, 0, false)
+		f, err := os.Open(names[x])
+		if err == nil {
+			err = ioctl(f, gpioV2GetLineIoctl, unsafe.Pointer(&reqs[x]))
+			f.Close()
+		}
+		if err != nil {
+			g.close()
+			return nil, err
+		}
+		g.lines = append(g.lines, os.NewFile(uintptr(reqs[x].fd), names[x]))
+	}
+	return g, nil
+}
+
+func (g *cdevGroup) read() (bits uint64, err error) {
+	for x, line := range g.lines {
+		values := gpioV2LineValues{mask: ^uint64(0) >> uint(64-len(g.index[x]))}
+		err = ioctl(line, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values))
+		if err != nil {
+			return
+		}
+		for j, i := range g.index[x] {
+			if values.bits&(1<<uint(j)) != 0 {
+				bits |= 1 << uint(i)
+			}
+		}
+	}
+	return
+}
+
+func (g *cdevGroup) write(bits, mask uint64) error {
+	for x, line := range g.lines {
+		var values gpioV2LineValues
+		for j, i := range g.index[x] {
+			if mask&(1<<uint(i)) != 0 {
+				values.mask |= 1 << uint(j)
+			}
+			if bits&(1<<uint(i)) != 0 {
+				values.bits |= 1 << uint(j)
+			}
+		}
+		if values.mask == 0 {
+			continue
+		}
+		err := ioctl(line, gpioV2LineSetValuesIoctl, unsafe.Pointer(&values))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *cdevGroup) setDirection(dir string) error {
+	flags, v := uint64(gpioV2LineFlagOutput), false
+	switch dir {
+	case "in":
+		flags = gpioV2LineFlagInput
+	case "out", "low":
+	case "high":
+		v = true
+	default:
+		return syscall.EINVAL
+	}
+	for x, line := range g.lines {
+		config := g.config(x, flags, v)
+		err := ioctl(line, gpioV2LineSetConfigIoctl, unsafe.Pointer(&config))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *cdevGroup) close() (err error) {
+	for _, line := range g.lines {
+		if e := line.Close(); err == nil {
+			err = e
+		}
+	}
+	g.lines = nil
+	return
+}
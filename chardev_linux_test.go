@@ -0,0 +1,105 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestLineInfoV2(t *testing.T) {
+	info := gpioV2LineInfo{
+		offset:   3,
+		numAttrs: 2,
+		flags: gpioV2LineFlagUsed | gpioV2LineFlagInput |
+			gpioV2LineFlagEdgeRising | gpioV2LineFlagBiasPullUp,
+	}
+	copy(info.name[:], "button")
+	copy(info.consumer[:], "gpiomon")
+	info.attrs[0] = gpioV2LineAttribute{id: gpioV2LineAttrIdDebounce,
+		value: 5000}
+	info.attrs[1] = gpioV2LineAttribute{id: gpioV2LineAttrIdOutputValues,
+		value: 1}
+	// Beyond numAttrs.
+	info.attrs[2] = gpioV2LineAttribute{id: gpioV2LineAttrIdDebounce,
+		value: 1}
+	want := LineInfoV2{
+		Name:     "button",
+		Consumer: "gpiomon",
+		Offset:   3,
+		Flags: LineFlags{Used: true, Input: true, EdgeRising: true,
+			PullUp: true},
+		Debounce:        5 * time.Millisecond,
+		OutputValues:    1,
+		HasOutputValues: true,
+	}
+	if got := lineInfoV2(&info); !reflect.DeepEqual(got, want) {
+		t.Errorf("info %+v, want %+v", got, want)
+	}
+}
+
+// The structs must match linux/gpio.h, whose sizes are in the ioctl
+// numbers.
+func TestChardevLayout(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		size, want uintptr
+	}{
+		{"gpiochip_info", unsafe.Sizeof(gpiochipInfo{}), 68},
+		{"gpio_v2_line_attribute", unsafe.Sizeof(gpioV2LineAttribute{}), 16},
+		{"gpio_v2_line_config_attribute",
+			unsafe.Sizeof(gpioV2LineConfigAttribute{}), 24},
+		{"gpio_v2_line_config", unsafe.Sizeof(gpioV2LineConfig{}), 272},
+		{"gpio_v2_line_request", unsafe.Sizeof(gpioV2LineRequest{}), 592},
+		{"gpio_v2_line_info", unsafe.Sizeof(gpioV2LineInfo{}), 256},
+		{"gpio_v2_line_values", unsafe.Sizeof(gpioV2LineValues{}), 16},
+		{"gpio_v2_line_event", unsafe.Sizeof(gpioV2LineEvent{}), 48},
+	} {
+		if tt.size != tt.want {
+			t.Errorf("%s: %d bytes, want %d", tt.name, tt.size, tt.want)
+		}
+	}
+	var req gpioV2LineRequest
+	var info gpioV2LineInfo
+	var ev gpioV2LineEvent
+	for _, tt := range []struct {
+		name         string
+		offset, want uintptr
+	}{
+		{"request.consumer", unsafe.Offsetof(req.consumer), 256},
+		{"request.config", unsafe.Offsetof(req.config), 288},
+		{"request.num_lines", unsafe.Offsetof(req.numLines), 560},
+		{"request.fd", unsafe.Offsetof(req.fd), 588},
+		{"info.offset", unsafe.Offsetof(info.offset), 64},
+		{"info.flags", unsafe.Offsetof(info.flags), 72},
+		{"info.attrs", unsafe.Offsetof(info.attrs), 80},
+		{"event.id", unsafe.Offsetof(ev.id), 8},
+		{"event.line_seqno", unsafe.Offsetof(ev.lineSeqno), 20},
+	} {
+		if tt.offset != tt.want {
+			t.Errorf("%s at %d, want %d", tt.name, tt.offset, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		name      string
+		ioc, want uintptr
+	}{
+		{"GPIO_GET_CHIPINFO_IOCTL", gpioGetChipInfoIoctl, 0x8044b401},
+		{"GPIO_V2_GET_LINEINFO_IOCTL", gpioV2GetLineInfoIoctl, 0xc100b405},
+		{"GPIO_V2_GET_LINE_IOCTL", gpioV2GetLineIoctl, 0xc250b407},
+		{"GPIO_V2_LINE_SET_CONFIG_IOCTL", gpioV2LineSetConfigIoctl,
+			0xc110b40d},
+		{"GPIO_V2_LINE_GET_VALUES_IOCTL", gpioV2LineGetValuesIoctl,
+			0xc010b40e},
+		{"GPIO_V2_LINE_SET_VALUES_IOCTL", gpioV2LineSetValuesIoctl,
+			0xc010b40f},
+	} {
+		if tt.ioc != tt.want {
+			t.Errorf("%s %#x, want %#x", tt.name, tt.ioc, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import "errors"
+
+var errNoChardev = errors.New("gpio character device requires linux")
+
+func (chardevBackend) Export(*Pin) error               { return errNoChardev }
+func (chardevBackend) Unexport(*Pin) error             { return errNoChardev }
+func (chardevBackend) IsExported(*Pin) bool            { return false }
+func (chardevBackend) Direction(*Pin) (string, error)  { return "", errNoChardev }
+func (chardevBackend) SetDirection(*Pin, string) error { return errNoChardev }
+func (chardevBackend) Write(*Pin, bool) error          { return errNoChardev }
+func (chardevBackend) Read(*Pin) (bool, error)         { return false, errNoChardev }
+
+func (chardevBackend) Watch(*Pin, Edge) (<-chan Event, error) {
+	return nil, errNoChardev
+}
+
+func cdevLineNames(string) ([]string, error) { return nil, errNoChardev }
+
+func (*Line) Info() (LineInfoV2, error) { return LineInfoV2{}, errNoChardev }
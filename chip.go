@@ -0,0 +1,241 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/platinasystems/fdt"
+)
+
+// Chip is a gpio controller as listed by Chips.
+type Chip struct {
+	// Name of the chip in /sys/class/gpio, "gpiochip<base>".
+	Name string
+	// Name of the chip's character device, e.g. "gpiochip0".
+	Dev string
+	// Label reported by the kernel driver.
+	Label string
+	// Chip has GPIOs base through base + Ngpio - 1.
+	Base, Ngpio int
+	// Value of compatible=XXX node in DTS file for this GPIO chip.
+	Compatible map[string]bool
+	// Path of the chip's device tree node, if it has one.
+	OfNode string
+}
+
+func (c *Chip) String() string {
+	return fmt.Sprintf("%s (%s): %d-%d", c.Name, c.Label, c.Base,
+		c.Base+c.Ngpio-1)
+}
+
+// Contains reports whether the global gpio number is one of the chip's lines.
+func (c *Chip) Contains(gpio int) bool {
+	return gpio >= c.Base && gpio < c.Base+c.Ngpio
+}
+
+// Pin returns the pin of the chip's line at offset: the configured pin of
+// that gpio, if there is one, or an unnamed one.
+func (c *Chip) Pin(offset int) (*Pin, error) {
+	if offset < 0 || offset >= c.Ngpio {
+		return nil, fmt.Errorf("%s: no line %d", c, offset)
+	}
+	return pinOfGpio(c.Base + offset), nil
+}
+
+// Chip returns the chip the pin is a line of, or nil if no chip has it or
+// the pin is of a backend that numbers its own lines.
+func (p *Pin) Chip() *Chip {
+	if !p.onChip() {
+		return nil
+	}
+	for _, c := range Chips() {
+		if c.Contains(p.Gpio) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Chips returns the gpio controllers listed in /sys/class/gpio sorted by
+// base. Chips whose attributes can't be read are skipped.
+func Chips() (chips []*Chip) {
+	dirs, _ := filepath.Glob(prefix + "/sys/class/gpio/gpiochip*")
+	for _, dir := range dirs {
+		c := &Chip{Name: filepath.Base(dir)}
+		if _, err := fmt.Sscan(readAttr(dir, "base"), &c.Base); err != nil {
+			continue
+		}
+		if _, err := fmt.Sscan(readAttr(dir, "ngpio"), &c.Ngpio); err != nil {
+			continue
+		}
+		c.Label = readAttr(dir, "label")
+		if m, _ := filepath.Glob(dir + "/device/gpiochip*"); len(m) != 0 {
+			c.Dev = filepath.Base(m[0])
+		}
+		c.OfNode = ofNode(dir + "/device")
+		c.Compatible = make(map[string]bool)
+		b, _ := ioutil.ReadFile(dir + "/device/of_node/compatible")
+		for _, s := range strings.Split(string(b), "\x00") {
+			if s != "" {
+				c.Compatible[s] = true
+			}
+		}
+		chips = append(chips, c)
+	}
+	sort.Slice(chips, func(i, j int) bool {
+		return chips[i].Base < chips[j].Base
+	})
+	return
+}
+
+// ofNode returns the device tree path of the device in the given sysfs
+// directory, or "" if it has none.
+func ofNode(dir string) string {
+	t, err := os.Readlink(dir + "/of_node")
+	if err != nil {
+		return ""
+	}
+	i := strings.Index(t, "/devicetree/base")
+	if i < 0 {
+		return ""
+	}
+	if t = t[i+len("/devicetree/base"):]; t == "" {
+		t = "/"
+	}
+	return t
+}
+
+// Character devices of banks found by discoverBank.
+var bankDevs = make(map[string]string)
+
+// discoverBank finds the kernel's gpiochip of a controller node, of the
+// given bank, by its device tree node, and notes the chip's base in
+// GpioBankToBase and character device in bankDevs.
+func discoverBank(n *fdt.Node, bank string) {
+	path := nodePaths[n]
+	for _, c := range Chips() {
+		if c.OfNode == path {
+			regMu.Lock()
+			GpioBankToBase[bank] = c.Base
+			if c.Dev != "" {
+				bankDevs[bank] = c.Dev
+			}
+			regMu.Unlock()
+			return
+		}
+	}
+	// Without sysfs there are still the character devices.
+	devs, _ := filepath.Glob(prefix + "/sys/bus/gpio/devices/gpiochip*")
+	for _, dir := range devs {
+		if ofNode(dir) == path {
+			regMu.Lock()
+			bankDevs[bank] = filepath.Base(dir)
+			regMu.Unlock()
+			return
+		}
+	}
+}
+
+func readAttr(dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// ValidateAgainstHardware checks the device tree derived pins against the
+// chips reported by the kernel, returning a PinErrors naming each pin whose
+// gpio isn't a line of any chip. Pins of backends that number their own
+// lines, e.g. an Expander, aren't checked.
+func ValidateAgainstHardware() error {
+	var l []*Pin
+	for _, p := range pinList() {
+		if p.onChip() {
+			l = append(l, p)
+		}
+	}
+	chips := Chips()
+	if len(chips) == 0 && len(l) != 0 {
+		return fmt.Errorf("no gpiochips found for %d pins", len(l))
+	}
+	errs := make(PinErrors)
+	for _, p := range l {
+		var c *Chip
+		for _, x := range chips {
+			if x.Base <= p.Gpio {
+				c = x
+			}
+		}
+		switch {
+		case c == nil:
+			errs[p] = fmt.Errorf("gpio %d is below the first chip %s",
+				p.Gpio, chips[0])
+		case !c.Contains(p.Gpio):
+			errs[p] = fmt.Errorf("gpio %d exceeds %s", p.Gpio, c)
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// DiffChips compares two snapshots of Chips and returns the sorted keys of
+// the chips only in b, only in a, and in both but with a different base or
+// ngpio, e.g. after a kernel update renumbered the controllers. Chips are
+// matched, and keyed, by label and device tree node, or character device
+// for those without one, "label (node)", so that identical expanders are
+// told apart and a renumbered chip isn't seen as another; chips with
+// neither go by name.
+func DiffChips(a, b []Chip) (added, removed, changed []string) {
+	key := func(c *Chip) string {
+		id := c.OfNode
+		if id == "" {
+			id = c.Dev
+		}
+		switch {
+		case c.Label == "" && id == "":
+			return c.Name
+		case id == "":
+			return c.Label
+		case c.Label == "":
+			return id
+		}
+		return fmt.Sprintf("%s (%s)", c.Label, id)
+	}
+	am := make(map[string]*Chip, len(a))
+	for i := range a {
+		am[key(&a[i])] = &a[i]
+	}
+	bm := make(map[string]*Chip, len(b))
+	for i := range b {
+		bm[key(&b[i])] = &b[i]
+	}
+	for k, c := range bm {
+		x, f := am[k]
+		switch {
+		case !f:
+			added = append(added, k)
+		case x.Base != c.Base || x.Ngpio != c.Ngpio:
+			changed = append(changed, k)
+		}
+	}
+	for k := range am {
+		if _, f := bm[k]; !f {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return
+}
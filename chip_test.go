@@ -0,0 +1,55 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/platinasystems/gpio"
+)
+
+func TestDiffChips(t *testing.T) {
+	soc := gpio.Chip{Name: "gpiochip0", Dev: "gpiochip0",
+		Label: "pinctrl", OfNode: "/soc/gpio@0", Base: 0, Ngpio: 32}
+	exp := gpio.Chip{Name: "gpiochip32", Dev: "gpiochip1",
+		Label: "pca9555", OfNode: "/soc/i2c@0/gpio@20", Base: 32,
+		Ngpio: 16}
+	usb := gpio.Chip{Name: "gpiochip480", Dev: "gpiochip2",
+		Label: "cp2112", Base: 480, Ngpio: 8}
+	// After the update the expander comes up at another base, and a
+	// second USB adapter, of the same label, is plugged in.
+	shifted := exp
+	shifted.Name, shifted.Base = "gpiochip48", 48
+	usb2 := gpio.Chip{Name: "gpiochip472", Dev: "gpiochip3",
+		Label: "cp2112", Base: 472, Ngpio: 8}
+	mockup := gpio.Chip{Name: "gpiochip500", Base: 500, Ngpio: 4}
+
+	added, removed, changed := gpio.DiffChips(
+		[]gpio.Chip{soc, exp, usb, mockup},
+		[]gpio.Chip{soc, shifted, usb, usb2})
+	for _, tt := range []struct {
+		what      string
+		got, want []string
+	}{
+		{"added", added, []string{"cp2112 (gpiochip3)"}},
+		{"removed", removed, []string{"gpiochip500"}},
+		{"changed", changed, []string{"pca9555 (/soc/i2c@0/gpio@20)"}},
+	} {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s %q, want %q", tt.what, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDiffChipsSame(t *testing.T) {
+	l := []gpio.Chip{{Name: "gpiochip0", Label: "pinctrl", Base: 0,
+		Ngpio: 32}}
+	added, removed, changed := gpio.DiffChips(l, l)
+	if added != nil || removed != nil || changed != nil {
+		t.Errorf("diff of the same chips %q %q %q", added, removed,
+			changed)
+	}
+}
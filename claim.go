@@ -0,0 +1,130 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import "fmt"
+
+// Owners of claimed pins, guarded by mu.
+var claims = make(map[*Pin]string)
+
+// ClaimError is the failure to claim a pin that's already claimed; it
+// matches ErrBusy.
+type ClaimError struct {
+	Pin   *Pin
+	Owner string
+	// The process holding a TryClaim lock, or 0 for this one.
+	PID int
+}
+
+func (e *ClaimError) Error() string {
+	if e.PID != 0 {
+		return fmt.Sprintf("%s: claimed by %s (pid %d)", e.Pin.Name,
+			e.Owner, e.PID)
+	}
+	return fmt.Sprintf("%s: claimed by %s", e.Pin.Name, e.Owner)
+}
+
+func (e *ClaimError) Is(target error) bool { return target == ErrBusy }
+
+// Claim reserves the pin FindPin finds by name for owner, e.g. the name of
+// a subsystem, failing with a ClaimError if another owner has it. Claims
+// are advisory: they keep users that claim from sharing a pin, they don't
+// stop writes.
+func Claim(name, owner string) error {
+	p, err := lookupPin(name)
+	if err != nil {
+		return err
+	}
+	return p.Claim(owner)
+}
+
+// Release gives up owner's claim of the named pin.
+func Release(name, owner string) error {
+	p, err := lookupPin(name)
+	if err != nil {
+		return err
+	}
+	return p.Unclaim(owner)
+}
+
+// Claim reserves the pin for owner; claiming it again is fine.
+func (p *Pin) Claim(owner string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if o, f := claims[p]; f && o != owner {
+		return &ClaimError{Pin: p, Owner: o}
+	}
+	claims[p] = owner
+	return nil
+}
+
+// Unclaim gives up owner's claim of the pin, and any TryClaim lock,
+// failing with a ClaimError if someone else has it.
+func (p *Pin) Unclaim(owner string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if o, f := claims[p]; f && o != owner {
+		return &ClaimError{Pin: p, Owner: o}
+	}
+	delete(claims, p)
+	if p.lock != nil {
+		p.lock.Close()
+		p.lock = nil
+	}
+	return nil
+}
+
+// TryClaim is Claim of the named pin across processes.
+func TryClaim(name, owner string) error {
+	p, err := lookupPin(name)
+	if err != nil {
+		return err
+	}
+	return p.TryClaim(owner)
+}
+
+// TryClaim claims the pin for owner then takes an exclusive flock of its
+// lock file in LockDir, so daemons sharing a system can't both claim it;
+// the lock file names the holder's pid and owner for the ClaimError of the
+// others. The lock goes with Unclaim or the process. Pins of the character
+// device backend are also kept exclusive by their line requests.
+func (p *Pin) TryClaim(owner string) error {
+	if err := p.Claim(owner); err != nil {
+		return err
+	}
+	mu.Lock()
+	locked := p.lock != nil
+	mu.Unlock()
+	if locked {
+		return nil
+	}
+	f, err := lockPin(p, owner)
+	if err != nil {
+		p.Unclaim(owner)
+		return err
+	}
+	mu.Lock()
+	p.lock = f
+	mu.Unlock()
+	return nil
+}
+
+// Owner returns the owner of the pin's claim, or "" if it's unclaimed.
+func (p *Pin) Owner() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return claims[p]
+}
+
+// Claims returns the owner of each claimed pin by pin name.
+func Claims() map[string]string {
+	mu.Lock()
+	defer mu.Unlock()
+	m := make(map[string]string, len(claims))
+	for p, o := range claims {
+		m[p.Name] = o
+	}
+	return m
+}
@@ -0,0 +1,53 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+func TestClaim(t *testing.T) {
+	gpio.InitFromTree(nil, gpio.WithOffline())
+	p := &gpio.Pin{Gpio: 1, Name: "fan_pwm", Backend: gpiotest.New()}
+	if err := gpio.RegisterPins(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := gpio.Claim("fan_pwm", "fand"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gpio.Claim("fan_pwm", "fand"); err != nil {
+		t.Errorf("claim again: %v", err)
+	}
+	err := gpio.Claim("fan_pwm", "thermald")
+	var ce *gpio.ClaimError
+	if !errors.As(err, &ce) || ce.Owner != "fand" ||
+		!errors.Is(err, gpio.ErrBusy) {
+		t.Errorf("contended claim: %v", err)
+	}
+	if err = gpio.Release("fan_pwm", "thermald"); err == nil {
+		t.Error("released another's claim")
+	}
+	if o := gpio.Claims()["fan_pwm"]; o != "fand" {
+		t.Errorf("claimed by %q, want fand", o)
+	}
+	if err = gpio.Release("fan_pwm", "fand"); err != nil {
+		t.Fatal(err)
+	}
+	if o := p.Owner(); o != "" {
+		t.Errorf("owner %q after release", o)
+	}
+	if err = gpio.Claim("fan_pwm", "thermald"); err != nil {
+		t.Errorf("claim after release: %v", err)
+	}
+	p.Unclaim("thermald")
+	if err = gpio.Claim("nonesuch", "fand"); !errors.Is(err,
+		gpio.ErrNoSuchPin) {
+		t.Errorf("claim of no pin: %v", err)
+	}
+}
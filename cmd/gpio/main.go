@@ -0,0 +1,207 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Command gpio lists, reads, sets and watches the device tree's gpio pins by
+// name. Values are logical, so an active-low pin reads 1 when asserted.
+//
+//	gpio [-fdt FILE] [-chardev] list
+//	gpio [-fdt FILE] [-chardev] get NAME
+//	gpio [-fdt FILE] [-chardev] set NAME 0|1
+//	gpio [-fdt FILE] [-chardev] watch NAME [rising|falling|both]
+//	gpio [-fdt FILE] [-chardev] monitor [-format json|csv] NAME...
+//
+// Monitor logs the edges of the pins, e.g. to a file to tail, as JSON lines
+// or CSV records.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: gpio [-fdt FILE] [-chardev] COMMAND
+	list			list the pins and their state
+	get NAME		print the pin's value, 0 or 1
+	set NAME 0|1		drive the pin to the value
+	watch NAME [EDGE]	print the pin's edges, rising, falling or both
+	monitor [-format F] NAME...
+				log the pins' edges, format json or csv
+`)
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	fdt := flag.String("fdt", "", "flattened device tree `file` to use instead of /proc/device-tree")
+	chardev := flag.Bool("chardev", false, "use the gpio character devices instead of sysfs")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+	if *chardev {
+		gpio.UseChardev(true)
+	}
+	var opts []gpio.Option
+	if *fdt != "" {
+		opts = append(opts, gpio.WithFDT(*fdt))
+	}
+	if err := gpio.Init(opts...); err != nil && gpio.NumPins() == 0 {
+		fatal(err)
+	}
+	args := flag.Args()
+	var err error
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		err = list()
+	case args[0] == "get" && len(args) == 2:
+		var v bool
+		if v, err = gpio.Value(args[1]); err == nil {
+			fmt.Println(b2i(v))
+		}
+	case args[0] == "set" && len(args) == 3:
+		var p *gpio.Pin
+		if p, err = pin(args[1]); err == nil {
+			switch args[2] {
+			case "0":
+				err = p.SetDirection("low")
+			case "1":
+				err = p.SetDirection("high")
+			default:
+				usage()
+			}
+		}
+	case args[0] == "watch" && (len(args) == 2 || len(args) == 3):
+		edge := gpio.EdgeBoth
+		if len(args) == 3 {
+			if edge = parseEdge(args[2]); edge == gpio.EdgeNone {
+				usage()
+			}
+		}
+		err = watch(args[1], edge)
+	case args[0] == "monitor" && len(args) >= 2:
+		err = monitor(args[1:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// list prints a table of the pins with their state.
+func list() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tGPIO\tDIRECTION\tVALUE\tDEFAULT\tACTIVE LOW")
+	for _, p := range gpio.SortedPins() {
+		dir, v := "?", "?"
+		if d, err := p.Direction(); err == nil {
+			dir = d
+		}
+		if x, err := p.Value(); err == nil {
+			v = fmt.Sprint(b2i(x))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%v\n", p.Name, p.Gpio, dir,
+			v, p.Default, p.ActiveLow)
+	}
+	return w.Flush()
+}
+
+func pin(name string) (*gpio.Pin, error) {
+	p, f := gpio.FindPin(name)
+	if !f {
+		return nil, fmt.Errorf("%s: %w", name, gpio.ErrNoSuchPin)
+	}
+	return p, nil
+}
+
+func parseEdge(s string) gpio.Edge {
+	for _, e := range []gpio.Edge{gpio.EdgeRising, gpio.EdgeFalling,
+		gpio.EdgeBoth} {
+		if e.String() == s {
+			return e
+		}
+	}
+	return gpio.EdgeNone
+}
+
+// watch prints the pin's edges until interrupted.
+func watch(name string, edge gpio.Edge) error {
+	p, err := pin(name)
+	if err != nil {
+		return err
+	}
+	c, err := p.Watch(edge)
+	if err != nil {
+		return err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	for {
+		select {
+		case e, ok := <-c:
+			if !ok {
+				return fmt.Errorf("%s: watch ended", name)
+			}
+			edge := "falling"
+			if e.Rising {
+				edge = "rising"
+			}
+			fmt.Printf("%s %s %d\n", e.Time.Format(time.RFC3339Nano),
+				edge, b2i(e.Value))
+		case <-sig:
+			_, err = p.Watch(gpio.EdgeNone)
+			return err
+		}
+	}
+}
+
+// monitor logs the edges of the pins until interrupted.
+func monitor(args []string) error {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	format := fs.String("format", "json", "event `format`, json or csv")
+	fs.Usage = usage
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		usage()
+	}
+	m, err := gpio.NewEventMonitor()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	for _, name := range fs.Args() {
+		p, err := pin(name)
+		if err != nil {
+			return err
+		}
+		if err = m.Add(p, gpio.EdgeBoth); err != nil {
+			return err
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return m.StreamTo(ctx, bufio.NewWriter(os.Stdout), *format)
+}
+
+func b2i(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gpio:", err)
+	os.Exit(1)
+}
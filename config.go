@@ -0,0 +1,146 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// PinConfig describes the pins of a platform whose device tree doesn't, e.g.
+//
+//	{"pins": [
+//		{"name": "led0", "bank": "gpio1", "offset": 5,
+//		 "default": "output-low", "active-low": true},
+//		{"name": "reset", "offset": 201, "default": "output-high"}
+//	]}
+type PinConfig struct {
+	Pins []PinConfigEntry `json:"pins"`
+}
+
+// PinConfigEntry is a pin of a PinConfig. Offset is the line within the
+// bank, a GpioBankToBase alias, or without a bank the global gpio number.
+// Default is a device tree mode, "output-high", "output-low" or "input", or
+// the direction itself, "high", "low" or "in"; empty for none.
+type PinConfigEntry struct {
+	Name      string `json:"name"`
+	Bank      string `json:"bank,omitempty"`
+	Offset    int    `json:"offset"`
+	Default   string `json:"default,omitempty"`
+	ActiveLow bool   `json:"active-low,omitempty"`
+	Label     string `json:"label,omitempty"`
+}
+
+// LoadPinConfig adds the pins of the JSON PinConfig read from r, replacing
+// configured pins of the same name, or with replace all pins from the device
+// tree. The entries are all checked before any pin is added; the pins that
+// could be added are kept whatever the error.
+func LoadPinConfig(r io.Reader, replace bool) error {
+	var c PinConfig
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return fmt.Errorf("pin config: %v", err)
+	}
+	modes, err := c.check()
+	if err != nil {
+		return fmt.Errorf("pin config: %v", err)
+	}
+	gpioInit()
+	if replace {
+		buildRegistry(nil, false)
+	}
+	return c.add(modes)
+}
+
+// check validates the entries, returning the GpioPinMode of each.
+func (c *PinConfig) check() (modes []string, err error) {
+	modes = make([]string, len(c.Pins))
+	seen := make(map[string]bool)
+	for i, e := range c.Pins {
+		switch {
+		case e.Name == "":
+			return nil, fmt.Errorf("pin %d: no name", i)
+		case seen[e.Name]:
+			return nil, fmt.Errorf("%s: duplicate pin", e.Name)
+		case e.Offset < 0:
+			return nil, fmt.Errorf("%s: negative offset", e.Name)
+		}
+		seen[e.Name] = true
+		if e.Bank != "" {
+			if _, f := bankBase(e.Bank); !f {
+				return nil, fmt.Errorf("%s: unknown bank %s",
+					e.Name, e.Bank)
+			}
+		}
+		if modes[i] = configMode(e.Default); modes[i] == "" &&
+			e.Default != "" {
+			return nil, fmt.Errorf("%s: bad default %q",
+				e.Name, e.Default)
+		}
+	}
+	return
+}
+
+// add makes the pins of the checked entries.
+func (c *PinConfig) add(modes []string) error {
+	var errs errorList
+	for i, e := range c.Pins {
+		regMu.Lock()
+		for l, p := range labels {
+			if p.Name == e.Name {
+				delete(labels, l)
+			}
+		}
+		regMu.Unlock()
+		p, err := newPin(e.Name, modes[i], e.Bank, strconv.Itoa(e.Offset))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		p.Label = p.Name
+		if e.Label != "" && e.Label != e.Name {
+			p.Label = e.Label
+			regMu.Lock()
+			labels[p.Label] = p
+			regMu.Unlock()
+		}
+		if e.ActiveLow {
+			if err = p.initActiveLow(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs.err()
+}
+
+// LoadPinConfigFile is LoadPinConfig of the named file.
+func LoadPinConfigFile(fn string, replace bool) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = LoadPinConfig(f, replace); err != nil {
+		return fmt.Errorf("%s: %v", fn, err)
+	}
+	return nil
+}
+
+// configMode returns the GpioPinMode key for a config default, or "" if it
+// names none.
+func configMode(def string) string {
+	if _, f := GpioPinMode[def]; f {
+		return def
+	}
+	for mode, dir := range GpioPinMode {
+		if dir == def {
+			return mode
+		}
+	}
+	return ""
+}
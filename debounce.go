@@ -0,0 +1,77 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import "time"
+
+// debounceSetter is implemented by backends that debounce in the kernel.
+type debounceSetter interface {
+	setDebounce(p *Pin) error
+}
+
+// SetDebounce sets the pin's Debounce. The character device applies it to
+// the line, in hardware where the chip can; other builtin backends filter
+// the edges of watches started after the change.
+func (p *Pin) SetDebounce(d time.Duration) (err error) {
+	defer p.record("set debounce", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	p.Debounce = d
+	if x, f := p.backend().(debounceSetter); f {
+		err = x.setDebounce(p)
+	}
+	return
+}
+
+// debouncer reports the changes of its input's level that last, from an
+// edgeSource watching both edges, as the given edges.
+type debouncer struct {
+	d     time.Duration
+	edge  Edge
+	level bool
+	// Most recent level seen while waiting for it to last, and when.
+	waiting bool
+	pending bool
+	since   time.Time
+}
+
+func newDebouncer(d time.Duration, edge Edge, level bool) *debouncer {
+	return &debouncer{d: d, edge: edge, level: level}
+}
+
+// timeout returns how long the debouncer can wait for more edges, or a
+// negative duration if it has nothing to report.
+func (db *debouncer) timeout(now time.Time) time.Duration {
+	if db == nil || !db.waiting {
+		return -1
+	}
+	if t := db.d - now.Sub(db.since); t > 0 {
+		return t
+	}
+	return 0
+}
+
+// filter takes the source's events and returns those to report as of now.
+func (db *debouncer) filter(events []EdgeEvent, now time.Time) []EdgeEvent {
+	if db == nil {
+		return events
+	}
+	for _, e := range events {
+		db.waiting, db.pending, db.since = true, e.Value, e.Time
+	}
+	if !db.waiting || now.Sub(db.since) < db.d {
+		return nil
+	}
+	db.waiting = false
+	if db.pending == db.level {
+		return nil
+	}
+	db.level = db.pending
+	if db.edge == EdgeRising && !db.level ||
+		db.edge == EdgeFalling && db.level {
+		return nil
+	}
+	return []EdgeEvent{{Rising: db.level, Value: db.level, Time: db.since}}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import "fmt"
+
+// Drive selects how an output drives its line. Sysfs emulates the single
+// ended drives by switching the pin to input for the released level.
+type Drive int
+
+const (
+	DrivePushPull Drive = iota
+	// DriveOpenDrain only drives the line low and lets it float high.
+	DriveOpenDrain
+	// DriveOpenSource only drives the line high and lets it float low.
+	DriveOpenSource
+)
+
+var driveNames = []string{
+	DrivePushPull:   "push-pull",
+	DriveOpenDrain:  "open-drain",
+	DriveOpenSource: "open-source",
+}
+
+func (d Drive) String() string {
+	if d >= 0 && int(d) < len(driveNames) {
+		return driveNames[d]
+	}
+	return fmt.Sprintf("Drive(%d)", int(d))
+}
+
+// driveSetter is implemented by backends that need to act on a change of a
+// pin's Drive.
+type driveSetter interface {
+	setDrive(p *Pin) error
+}
+
+// SetDrive sets the pin's Drive and, for an output line held through the
+// character device, applies it at once. Otherwise it applies from the
+// pin's next write or direction change.
+func (p *Pin) SetDrive(d Drive) (err error) {
+	defer p.record("set drive", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	p.Drive = d
+	if x, f := p.backend().(driveSetter); f {
+		err = x.setDrive(p)
+	}
+	return
+}
+
+// lineLow drives the pin's line low whatever its polarity, e.g. to pull
+// down an open-drain bus emulated by switching between output and input.
+func (p *Pin) lineLow() error {
+	if p.ActiveLow {
+		return p.SetDirection("high")
+	}
+	return p.SetDirection("low")
+}
+
+// lineLevel reads the level of the pin's line whatever its polarity.
+func (p *Pin) lineLevel() (bool, error) {
+	v, err := p.Value()
+	return v != p.ActiveLow, err
+}
@@ -0,0 +1,107 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// pinInfo is the JSON of a Pin, read live.
+type pinInfo struct {
+	Name      string `json:"name"`
+	Gpio      int    `json:"gpio"`
+	Chip      string `json:"chip,omitempty"`
+	Direction string `json:"direction,omitempty"`
+	Value     *bool  `json:"value,omitempty"`
+	Default   string `json:"default,omitempty"`
+	ActiveLow bool   `json:"active_low,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (p *Pin) info(chips []*Chip) (i pinInfo) {
+	i = pinInfo{Name: p.Name, Gpio: p.Gpio, Default: p.Default,
+		ActiveLow: p.ActiveLow}
+	if p.Label != p.Name {
+		i.Label = p.Label
+	}
+	if !p.onChip() {
+		i.Chip = backendName(p.Backend)
+	}
+	for _, c := range chips {
+		if c.Contains(p.Gpio) && p.onChip() {
+			i.Chip = c.Name
+		}
+	}
+	dir, err := p.Direction()
+	if err == nil {
+		i.Direction = dir
+		var v bool
+		if v, err = p.Value(); err == nil {
+			i.Value = &v
+		}
+	}
+	if err != nil {
+		i.Error = err.Error()
+	}
+	return
+}
+
+// MarshalJSON gives the pin's gpio, name, chip, default and its direction
+// and value as read now, or the error reading them.
+func (p *Pin) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.info(Chips()))
+}
+
+// MarshalJSON gives the pins in name order, as Pin.MarshalJSON.
+func (pm PinMap) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pm.infos())
+}
+
+func (pm PinMap) infos() []pinInfo {
+	chips := Chips()
+	l := make([]pinInfo, 0, len(pm))
+	for _, p := range pm {
+		l = append(l, p.info(chips))
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+// DumpState writes the state of every pin, as PinMap.MarshalJSON, in the
+// given format, "json" or "csv".
+func DumpState(w io.Writer, format string) error {
+	pm := make(PinMap)
+	for _, p := range pinList() {
+		pm[p.Name] = p
+	}
+	switch format {
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "\t")
+		return e.Encode(pm.infos())
+	case "csv":
+		c := csv.NewWriter(w)
+		c.Write([]string{"name", "gpio", "chip", "direction", "value",
+			"default", "active_low", "label", "error"})
+		for _, i := range pm.infos() {
+			v := ""
+			if i.Value != nil {
+				v = strconv.FormatBool(*i.Value)
+			}
+			c.Write([]string{i.Name, strconv.Itoa(i.Gpio), i.Chip,
+				i.Direction, v, i.Default,
+				strconv.FormatBool(i.ActiveLow), i.Label, i.Error})
+		}
+		c.Flush()
+		return c.Error()
+	}
+	return fmt.Errorf("unknown dump format %q", format)
+}
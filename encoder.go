@@ -0,0 +1,142 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Quadrature step of each transition from the previous AB state, the high
+// two bits of the index, to the next; 0 for none or an invalid jump over a
+// state, which a glitch or a missed edge makes.
+var quadrature = [16]int{0, -1, 1, 0, 1, 0, 0, -1, -1, 0, 0, 1, 0, 1, -1, 0}
+
+// Encoder decodes a rotary encoder's quadrature inputs into signed steps,
+// positive when A leads B. It watches both pins until closed.
+type Encoder struct {
+	A, B *Pin
+	// Quadrature transitions per step, usually 4 for a detent.
+	StepsPerDetent int
+
+	mu   sync.Mutex
+	pos  int
+	c    chan int
+	wg   sync.WaitGroup
+	evs  chan Event
+	done chan struct{}
+}
+
+// NewEncoder starts watching the pins, with four transitions a step.
+func NewEncoder(a, b *Pin) (*Encoder, error) {
+	e := &Encoder{A: a, B: b, StepsPerDetent: 4, c: make(chan int, 16),
+		evs: make(chan Event), done: make(chan struct{})}
+	state, err := e.state()
+	if err != nil {
+		return nil, err
+	}
+	var ws [2]<-chan Event
+	for i, p := range []*Pin{a, b} {
+		w, err := p.Watch(EdgeBoth)
+		if err != nil {
+			a.Watch(EdgeNone)
+			return nil, err
+		}
+		ws[i] = w
+	}
+	for _, w := range ws {
+		e.wg.Add(1)
+		go func(w <-chan Event) {
+			defer e.wg.Done()
+			for ev := range w {
+				e.evs <- ev
+			}
+		}(w)
+	}
+	go func() {
+		e.wg.Wait()
+		close(e.evs)
+	}()
+	go e.run(state)
+	return e, nil
+}
+
+// Steps returns the signed step counts as they happen; it's closed when the
+// encoder is. Counts not received in time add to the next.
+func (e *Encoder) Steps() <-chan int { return e.c }
+
+// Position returns the sum of the steps so far.
+func (e *Encoder) Position() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.pos
+}
+
+func (e *Encoder) String() string {
+	return fmt.Sprintf("encoder(%s,%s)", e.A, e.B)
+}
+
+// Close stops watching the pins.
+func (e *Encoder) Close() error {
+	_, err := e.A.Watch(EdgeNone)
+	if _, e := e.B.Watch(EdgeNone); err == nil {
+		err = e
+	}
+	<-e.done
+	return err
+}
+
+// state returns the AB state, A the high bit.
+func (e *Encoder) state() (state int, err error) {
+	for i, p := range []*Pin{e.A, e.B} {
+		v, err := p.Value()
+		if err != nil {
+			return 0, err
+		}
+		if v {
+			state |= 2 >> uint(i)
+		}
+	}
+	return
+}
+
+func (e *Encoder) run(state int) {
+	defer close(e.done)
+	defer close(e.c)
+	acc, pending := 0, 0
+	for {
+		var out chan int
+		if pending != 0 {
+			out = e.c
+		}
+		select {
+		case _, ok := <-e.evs:
+			if !ok {
+				return
+			}
+			// The pins' watches race, so go by their levels now
+			// rather than the order of their events.
+			next, err := e.state()
+			if err != nil {
+				continue
+			}
+			acc += quadrature[state<<2|next]
+			state = next
+			n := e.StepsPerDetent
+			if n <= 0 {
+				n = 1
+			}
+			if steps := acc / n; steps != 0 {
+				acc -= steps * n
+				pending += steps
+				e.mu.Lock()
+				e.pos += steps
+				e.mu.Unlock()
+			}
+		case out <- pending:
+			pending = 0
+		}
+	}
+}
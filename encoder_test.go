@@ -0,0 +1,70 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+func TestEncoder(t *testing.T) {
+	be := gpiotest.New()
+	aOut, a := be.Pair("a_out", "a", 0)
+	bOut, b := be.Pair("b_out", "b", 0)
+	e, err := gpio.NewEncoder(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// turn steps through n detents of AB states, A leading B if cw.
+	turn := func(n int, cw bool) {
+		seq := [][2]bool{{true, false}, {true, true}, {false, true},
+			{false, false}}
+		for i := 0; i < n; i++ {
+			for _, s := range seq {
+				if !cw {
+					s[0], s[1] = s[1], s[0]
+				}
+				aOut.SetValue(s[0])
+				bOut.SetValue(s[1])
+				// One edge at a time, as the encoder goes by the
+				// levels when it sees one.
+				time.Sleep(5 * time.Millisecond)
+			}
+		}
+	}
+	steps := func() (sum int) {
+		deadline := time.After(time.Second)
+		for {
+			select {
+			case n := <-e.Steps():
+				sum += n
+			case <-time.After(20 * time.Millisecond):
+				return
+			case <-deadline:
+				t.Fatal("steps keep coming")
+			}
+		}
+	}
+	turn(3, true)
+	if n := steps(); n != 3 {
+		t.Errorf("%d steps clockwise, want 3", n)
+	}
+	turn(2, false)
+	if n := steps(); n != -2 {
+		t.Errorf("%d steps counterclockwise, want -2", n)
+	}
+	if pos := e.Position(); pos != 1 {
+		t.Errorf("position %d, want 1", pos)
+	}
+	if err = e.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, ok := <-e.Steps(); ok {
+		t.Error("steps not closed by Close")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Failures that errors.Is distinguishes in the errors of pin operations.
+var (
+	// The pin's sysfs attributes or character device don't exist.
+	ErrNotExported = errors.New("gpio not exported")
+	ErrPermission  = errors.New("gpio permission denied")
+	// Another user holds the line, or it's already exported.
+	ErrBusy = errors.New("gpio busy")
+	// No pin has the name looked up.
+	ErrNoSuchPin    = errors.New("no such pin")
+	ErrBadDirection = errors.New("invalid gpio direction")
+)
+
+// PinError is the failure of an operation on a pin. It unwraps to the
+// backend's error, so errors.Is and errors.As see the os and syscall errors.
+type PinError struct {
+	Pin *Pin
+	// Operation, e.g. "set value".
+	Op  string
+	Err error
+}
+
+func (e *PinError) Error() string {
+	name := e.Pin.Name
+	if name == "" {
+		name = fmt.Sprintf("gpio%d", e.Pin.Gpio)
+	}
+	return fmt.Sprintf("%s: %s: %v", name, e.Op, e.Err)
+}
+
+func (e *PinError) Unwrap() error { return e.Err }
+
+// Is matches the package's sentinel errors to the backend errors that mean
+// them.
+func (e *PinError) Is(target error) bool {
+	switch target {
+	case ErrNotExported:
+		return errors.Is(e.Err, os.ErrNotExist)
+	case ErrPermission:
+		return errors.Is(e.Err, os.ErrPermission)
+	case ErrBusy:
+		return errors.Is(e.Err, syscall.EBUSY)
+	case ErrBadDirection:
+		return e.Op == "set direction" && errors.Is(e.Err, syscall.EINVAL)
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// EdgeEvent is a single transition of an input. Rising is true for a low to
+// high transition and Value is the level after the edge.
+//
+// The character device reports each edge's direction and kernel timestamp.
+// Sysfs only reports that an edge happened, so there Rising is that of the
+// watched edge or, watching both, the level read after the wakeup, and Time
+// is that of the wakeup.
+type EdgeEvent struct {
+	Rising bool
+	Value  bool
+	Time   time.Time
+}
+
+// newEdgeEvent derives the event for a change from prev to v.
+func newEdgeEvent(prev, v bool, t time.Time) EdgeEvent {
+	return EdgeEvent{Rising: !prev && v, Value: v, Time: t}
+}
+
+func (e EdgeEvent) String() string {
+	edge := "falling"
+	if e.Rising {
+		edge = "rising"
+	}
+	return fmt.Sprintf("%s %s", e.Time.Format(time.RFC3339Nano), edge)
+}
+
+// Edge selects the transitions of an input to report.
+type Edge int
+
+const (
+	EdgeNone Edge = iota
+	EdgeRising
+	EdgeFalling
+	EdgeBoth
+)
+
+var edgeNames = []string{
+	EdgeNone:    "none",
+	EdgeRising:  "rising",
+	EdgeFalling: "falling",
+	EdgeBoth:    "both",
+}
+
+// String returns the edge's sysfs name.
+func (e Edge) String() string {
+	if e >= 0 && int(e) < len(edgeNames) {
+		return edgeNames[e]
+	}
+	return fmt.Sprintf("Edge(%d)", int(e))
+}
+
+// rising reports the direction of an edge seen while watching e with the
+// level read after it.
+func (e Edge) rising(v bool) bool {
+	switch e {
+	case EdgeRising:
+		return true
+	case EdgeFalling:
+		return false
+	}
+	return v
+}
+
+// Event is an edge of a watched pin.
+type Event struct {
+	Pin *Pin
+	EdgeEvent
+}
+
+// Watch configures the input to report the given edges and delivers them on
+// the returned channel. Watching again replaces the previous watch, closing
+// its channel; Watch(EdgeNone) just stops watching. A read error also ends
+// the watch and is left for FailedPins.
+func (p *Pin) Watch(edge Edge) (c <-chan Event, err error) {
+	defer p.record("watch", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	if c, err = p.backend().Watch(p, edge); err == nil {
+		if c != nil && !notesEdges(p.backend()) {
+			c = p.noteEdges(c)
+		}
+		if edge == EdgeNone {
+			p.log(slog.LevelInfo, "gpio watch stopped")
+		} else {
+			p.log(slog.LevelInfo, "gpio watch started", "edge", edge)
+		}
+	}
+	return
+}
+
+// notesEdges reports whether the backend's watches count their edges and
+// keep them for History themselves, as the builtin backends and Expander
+// do.
+func notesEdges(b Backend) bool {
+	switch b.(type) {
+	case edgeArmer, *Expander:
+		return true
+	}
+	return false
+}
+
+// noteEdges forwards the events of a watch of another backend, noting
+// their edges. Like the backends' watches it drops the events its reader
+// is too slow for, rather than block with the watch replaced.
+func (p *Pin) noteEdges(c <-chan Event) <-chan Event {
+	out := make(chan Event, watchDepth)
+	go func() {
+		defer close(out)
+		// Only this goroutine sends, so there's room for any edge noted.
+		for e := range c {
+			if len(out) < cap(out) {
+				p.noteEdge(e.EdgeEvent)
+				out <- e
+			}
+		}
+	}()
+	return out
+}
+
+// WaitForEdge blocks until the input has one of the given edges, returning
+// that edge, or until ctx is done, returning its error; use a context with a
+// deadline for a timeout. It replaces any watch of the pin and leaves the
+// pin unwatched.
+func (p *Pin) WaitForEdge(ctx context.Context, edge Edge) (e EdgeEvent, err error) {
+	if edge == EdgeNone {
+		return e, fmt.Errorf("%s: wait for no edge", p)
+	}
+	c, err := p.Watch(edge)
+	if err != nil {
+		return
+	}
+	defer p.Watch(EdgeNone)
+	select {
+	case ev, ok := <-c:
+		if !ok {
+			err = p.watchEnded()
+			return
+		}
+		e = ev.EdgeEvent
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// watchEnded returns why the pin's watch channel closed: the read error that
+// ended it, if any.
+func (p *Pin) watchEnded() error {
+	mu.Lock()
+	err := p.err
+	mu.Unlock()
+	if err == nil {
+		err = fmt.Errorf("%s: watch ended", p)
+	}
+	return err
+}
+
+// Events buffered by a watch.
+const watchDepth = 64
+
+// edgeSource is a descriptor that polls ready when a watched pin has
+// edges, and the means to read them.
+type edgeSource struct {
+	fd      int
+	events  uint32
+	read    func() ([]EdgeEvent, error)
+	cleanup func()
+	// Software debounce of the read edges, if any.
+	db *debouncer
+}
+
+// edgeArmer is implemented by the builtin backends, whose watches are
+// descriptors that may share an epoll loop. armEdge replaces any watch of
+// the pin; with EdgeNone it just disarms the pin and returns no source.
+type edgeArmer interface {
+	armEdge(p *Pin, edge Edge) (*edgeSource, error)
+}
+
+// watchArmed is the Watch of edgeArmer backends.
+func watchArmed(a edgeArmer, p *Pin, edge Edge) (<-chan Event, error) {
+	src, err := a.armEdge(p, edge)
+	if err != nil || src == nil {
+		return nil, err
+	}
+	return p.startWatch(src)
+}
+
+// watcher is the goroutine delivering the events of one of the builtin
+// backends' watches.
+type watcher struct {
+	c     chan Event
+	pl    *poller
+	stopc chan struct{}
+	done  chan struct{}
+}
+
+// startWatch makes the pin's watch one delivering the edges of src.
+func (p *Pin) startWatch(src *edgeSource) (<-chan Event, error) {
+	pl, err := newPoller()
+	if err == nil {
+		err = pl.add(src.fd, src.events)
+	}
+	if err != nil {
+		if pl != nil {
+			pl.close()
+		}
+		src.cleanup()
+		return nil, err
+	}
+	w := &watcher{
+		c:     make(chan Event, watchDepth),
+		pl:    pl,
+		stopc: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	mu.Lock()
+	p.watch = w
+	mu.Unlock()
+	go func() {
+		realtimeReader()
+		defer close(w.done)
+		defer close(w.c)
+		defer src.cleanup()
+		defer pl.close()
+		// Ended on its own, the watch is no longer the pin's, so that
+		// stopWatch doesn't stop a closed poller.
+		defer func() {
+			mu.Lock()
+			if p.watch == w {
+				p.watch = nil
+			}
+			mu.Unlock()
+		}()
+		for {
+			fds, ok, err := pl.wait(src.db.timeout(time.Now()))
+			if err == nil && ok {
+				var events []EdgeEvent
+				if len(fds) > 0 {
+					events, err = src.read()
+				}
+				if err == nil {
+					events = src.db.filter(events, time.Now())
+					for _, e := range events {
+						select {
+						case w.c <- Event{Pin: p, EdgeEvent: e}:
+							p.noteEdge(e)
+						case <-w.stopc:
+							return
+						}
+					}
+					continue
+				}
+			}
+			if err != nil {
+				p.record("watch", &err)
+				p.log(slog.LevelWarn, "gpio watch ended",
+					"err", err)
+			}
+			return
+		}
+	}()
+	return w.c, nil
+}
+
+// stopWatch ends the pin's watch, if it has one, and waits for its
+// goroutine to finish.
+func (p *Pin) stopWatch() {
+	mu.Lock()
+	w := p.watch
+	p.watch = nil
+	mu.Unlock()
+	if w == nil {
+		return
+	}
+	close(w.stopc)
+	w.pl.stop()
+	<-w.done
+}
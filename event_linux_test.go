@@ -0,0 +1,101 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// A watch ended by a read error mustn't be stopped again, writing to the
+// closed, maybe reused, descriptors of its poller.
+func TestWatchEndedByReadError(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	errRead := errors.New("read failed")
+	p := &Pin{Gpio: 1, Name: "alert"}
+	c, err := p.startWatch(&edgeSource{
+		fd:      int(r.Fd()),
+		events:  pollReadable,
+		read:    func() ([]EdgeEvent, error) { return nil, errRead },
+		cleanup: func() {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	wt := p.watch
+	mu.Unlock()
+	w.Write([]byte{1})
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Fatal("event from a failed read")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watch didn't end on a read error")
+	}
+	<-wt.done
+	mu.Lock()
+	left := p.watch
+	mu.Unlock()
+	if left != nil {
+		t.Error("ended watch still the pin's")
+	}
+	if !errors.Is(p.watchEnded(), errRead) {
+		t.Errorf("watch ended by %v, want %v", p.watchEnded(), errRead)
+	}
+	// New descriptors likely reuse the poller's numbers.
+	var fds [2]int
+	if err = syscall.Pipe2(fds[:], syscall.O_NONBLOCK); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+	p.stopWatch()
+	wt.pl.stop()
+	if n, _ := syscall.Read(fds[0], make([]byte, 1)); n > 0 {
+		t.Error("stop wrote to a reused descriptor")
+	}
+}
+
+func TestEventReaderRealtime(t *testing.T) {
+	called := make(chan struct{}, 1)
+	SetEventReaderPriority(func() error {
+		called <- struct{}{}
+		return errors.New("not permitted")
+	})
+	SetEventReaderRealtime(true)
+	defer SetEventReaderPriority(nil)
+	defer SetEventReaderRealtime(false)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	p := &Pin{Gpio: 2, Name: "fast"}
+	if _, err = p.startWatch(&edgeSource{
+		fd:      int(r.Fd()),
+		events:  pollReadable,
+		read:    func() ([]EdgeEvent, error) { return nil, nil },
+		cleanup: func() {},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.stopWatch()
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("priority hook not called")
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// I2CBus runs I2C transactions: a write of w then, after a repeated start,
+// a read of r. I2CDev and the i2cbb package's Master are I2CBuses.
+type I2CBus interface {
+	Tx(addr uint16, w, r []byte) error
+}
+
+// Register addresses of a 16 line expander, each the first of a pair for
+// lines 0-7 and 8-15.
+type expanderRegs struct {
+	input, output, config byte
+	// MCP23017 only, or 0.
+	pullUp, intEnable, ioconf byte
+}
+
+var (
+	mcp23017Regs = expanderRegs{input: 0x12, output: 0x14, config: 0x00,
+		pullUp: 0x0c, intEnable: 0x04, ioconf: 0x0a}
+	pca9555Regs = expanderRegs{input: 0x00, output: 0x02, config: 0x06}
+)
+
+// Expander is an MCP23017 or PCA9555 I2C gpio expander, and the Backend of
+// its 16 lines, Pins, which are added to the pin map as prefix and line,
+// e.g. "exp0.3".
+//
+// With the expander's interrupt output wired to IntPin the lines can be
+// watched; every change of the inputs asserts it, and the expander's input
+// register is read to find the lines that changed. Event times are those
+// of the interrupt.
+type Expander struct {
+	Bus    I2CBus
+	Addr   uint16
+	IntPin *Pin
+	Pins   []*Pin
+
+	regs expanderRegs
+	mu   sync.Mutex
+	// Cached output and config registers, and the last inputs read for
+	// watches.
+	output, config, input uint16
+	watches               map[int]*expanderWatch
+	intDone               chan struct{}
+}
+
+type expanderWatch struct {
+	p    *Pin
+	edge Edge
+	c    chan Event
+}
+
+// NewMCP23017 registers the lines of the MCP23017 at addr, mirroring its
+// two interrupt outputs so that either serves as intPin, which may be nil.
+func NewMCP23017(bus I2CBus, addr uint16, prefix string, intPin *Pin) (*Expander, error) {
+	return newExpander(bus, addr, prefix, intPin, mcp23017Regs)
+}
+
+// NewPCA9555 registers the lines of the PCA9555 at addr; intPin may be nil.
+func NewPCA9555(bus I2CBus, addr uint16, prefix string, intPin *Pin) (*Expander, error) {
+	return newExpander(bus, addr, prefix, intPin, pca9555Regs)
+}
+
+func newExpander(bus I2CBus, addr uint16, prefix string, intPin *Pin, regs expanderRegs) (*Expander, error) {
+	e := &Expander{Bus: bus, Addr: addr, IntPin: intPin, regs: regs,
+		watches: make(map[int]*expanderWatch)}
+	if regs.ioconf != 0 {
+		// IOCON.MIRROR; BANK stays 0 for paired registers.
+		if err := e.Bus.Tx(addr, []byte{regs.ioconf, 0x40}, nil); err != nil {
+			return nil, e.fail(err)
+		}
+	}
+	var err error
+	if e.output, err = e.read16(regs.output); err != nil {
+		return nil, err
+	}
+	if e.config, err = e.read16(regs.config); err != nil {
+		return nil, err
+	}
+	e.Pins = make([]*Pin, 16)
+	for i := range e.Pins {
+		e.Pins[i] = &Pin{Gpio: i, Name: fmt.Sprintf("%s%d", prefix, i),
+			Backend: e}
+	}
+	if err = RegisterPins(e.Pins...); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Expander) String() string { return fmt.Sprintf("expander %#x", e.Addr) }
+
+func (e *Expander) fail(err error) error {
+	return fmt.Errorf("%s: %v", e, err)
+}
+
+func (e *Expander) read16(reg byte) (uint16, error) {
+	b := make([]byte, 2)
+	if err := e.Bus.Tx(e.Addr, []byte{reg}, b); err != nil {
+		return 0, e.fail(err)
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, nil
+}
+
+func (e *Expander) write16(reg byte, v uint16) error {
+	if err := e.Bus.Tx(e.Addr, []byte{reg, byte(v), byte(v >> 8)}, nil); err != nil {
+		return e.fail(err)
+	}
+	return nil
+}
+
+func (e *Expander) line(p *Pin) (uint16, error) {
+	if p.Gpio < 0 || p.Gpio > 15 {
+		return 0, fmt.Errorf("%s: no line %d", e, p.Gpio)
+	}
+	return 1 << uint(p.Gpio), nil
+}
+
+// Lines need no export.
+func (e *Expander) Export(p *Pin) error {
+	_, err := e.line(p)
+	return err
+}
+
+func (e *Expander) Unexport(p *Pin) error { return nil }
+
+func (e *Expander) IsExported(p *Pin) bool {
+	_, err := e.line(p)
+	return err == nil
+}
+
+func (e *Expander) Direction(p *Pin) (string, error) {
+	bit, err := e.line(p)
+	if err != nil {
+		return "", err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.config&bit != 0 {
+		return "in", nil
+	}
+	return "out", nil
+}
+
+// SetDirection sets the output latch first for "high" and "low", so the
+// line doesn't glitch.
+func (e *Expander) SetDirection(p *Pin, dir string) error {
+	bit, err := e.line(p)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch dir {
+	case "in":
+		return e.setConfig(e.config | bit)
+	case "high", "low":
+		if err = e.setOutput(bit, (dir == "high") != p.ActiveLow); err != nil {
+			return err
+		}
+		fallthrough
+	case "out":
+		return e.setConfig(e.config &^ bit)
+	}
+	return fmt.Errorf("%s: invalid direction %q", e, dir)
+}
+
+func (e *Expander) setConfig(v uint16) error {
+	if err := e.write16(e.regs.config, v); err != nil {
+		return err
+	}
+	e.config = v
+	return nil
+}
+
+func (e *Expander) setOutput(bit uint16, level bool) error {
+	v := e.output &^ bit
+	if level {
+		v |= bit
+	}
+	if err := e.write16(e.regs.output, v); err != nil {
+		return err
+	}
+	e.output = v
+	return nil
+}
+
+func (e *Expander) Read(p *Pin) (bool, error) {
+	bit, err := e.line(p)
+	if err != nil {
+		return false, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	v, err := e.read16(e.regs.input)
+	if err != nil {
+		return false, err
+	}
+	return (v&bit != 0) != p.ActiveLow, nil
+}
+
+func (e *Expander) Write(p *Pin, v bool) error {
+	bit, err := e.line(p)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.setOutput(bit, v != p.ActiveLow)
+}
+
+// setBias applies BiasPullUp with the MCP23017's pull-ups; the PCA9555 has
+// none to set.
+func (e *Expander) setBias(p *Pin) error {
+	bit, err := e.line(p)
+	if err != nil {
+		return err
+	}
+	if e.regs.pullUp == 0 || p.Bias == BiasPullDown {
+		return ErrBiasUnsupported
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	v, err := e.read16(e.regs.pullUp)
+	if err != nil {
+		return err
+	}
+	v &^= bit
+	if p.Bias == BiasPullUp {
+		v |= bit
+	}
+	return e.write16(e.regs.pullUp, v)
+}
+
+func (e *Expander) Watch(p *Pin, edge Edge) (<-chan Event, error) {
+	_, err := e.line(p)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if w := e.watches[p.Gpio]; w != nil {
+		close(w.c)
+		delete(e.watches, p.Gpio)
+	}
+	if edge == EdgeNone {
+		return nil, e.setInterrupts()
+	}
+	if e.IntPin == nil {
+		return nil, fmt.Errorf("%s: no interrupt pin to watch with", e)
+	}
+	if len(e.watches) == 0 {
+		if e.input, err = e.read16(e.regs.input); err != nil {
+			return nil, err
+		}
+	}
+	w := &expanderWatch{p: p, edge: edge, c: make(chan Event, 64)}
+	e.watches[p.Gpio] = w
+	if err = e.setInterrupts(); err != nil {
+		delete(e.watches, p.Gpio)
+		return nil, err
+	}
+	return w.c, nil
+}
+
+// setInterrupts enables the interrupts of the watched lines and runs the
+// interrupt pin's watch while there are any.
+func (e *Expander) setInterrupts() error {
+	if e.regs.intEnable != 0 {
+		var v uint16
+		for i := range e.watches {
+			v |= 1 << uint(i)
+		}
+		if err := e.write16(e.regs.intEnable, v); err != nil {
+			return err
+		}
+	}
+	switch {
+	case len(e.watches) != 0 && e.intDone == nil:
+		c, err := e.IntPin.Watch(EdgeFalling)
+		if err != nil {
+			return err
+		}
+		e.intDone = make(chan struct{})
+		go e.interrupts(c, e.intDone)
+	case len(e.watches) == 0 && e.intDone != nil:
+		done := e.intDone
+		e.intDone = nil
+		e.mu.Unlock()
+		_, err := e.IntPin.Watch(EdgeNone)
+		<-done
+		e.mu.Lock()
+		return err
+	}
+	return nil
+}
+
+// interrupts reads the inputs on each interrupt, which also clears it, and
+// delivers the edges of the watched lines.
+func (e *Expander) interrupts(c <-chan Event, done chan struct{}) {
+	defer close(done)
+	for ev := range c {
+		e.mu.Lock()
+		v, err := e.read16(e.regs.input)
+		if err == nil {
+			e.deliver(v, ev.Time)
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *Expander) deliver(v uint16, t time.Time) {
+	changed := v ^ e.input
+	e.input = v
+	for i, w := range e.watches {
+		bit := uint16(1) << uint(i)
+		if changed&bit == 0 {
+			continue
+		}
+		x := (v&bit != 0) != w.p.ActiveLow
+		if (w.edge == EdgeRising && !x) || (w.edge == EdgeFalling && x) {
+			continue
+		}
+		ev := newEdgeEvent(!x, x, t)
+		select {
+		case w.c <- Event{Pin: w.p, EdgeEvent: ev}:
+			w.p.noteEdge(ev)
+		default:
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FanOutput is the PWM driving a fan, a Pwm or SoftPWM.
+type FanOutput interface {
+	SetDutyCycle(duty float64) error
+}
+
+// FanController holds a fan at a target RPM with a PI loop from its
+// tachometer to its PWM. Set the fields before Start.
+type FanController struct {
+	Out  FanOutput
+	Tach *Tachometer
+	// Target, if set, gives the target RPM each step, e.g. from a
+	// temperature; otherwise it's that of SetTarget.
+	Target func() float64
+	// Proportional gain in duty per RPM of error and integral gain in
+	// duty per RPM second.
+	Kp, Ki           float64
+	Interval         time.Duration
+	MinDuty, MaxDuty float64
+
+	mu       sync.Mutex
+	target   float64
+	integral float64
+	duty     float64
+	err      error
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewFanController returns a controller stepping every second with gains
+// that suit fans of a few thousand RPM, over the full duty range.
+func NewFanController(out FanOutput, tach *Tachometer) *FanController {
+	return &FanController{Out: out, Tach: tach, Kp: 1e-4, Ki: 5e-5,
+		Interval: time.Second, MaxDuty: 1}
+}
+
+// SetTarget sets the target RPM for a controller without Target.
+func (fc *FanController) SetTarget(rpm float64) {
+	fc.mu.Lock()
+	fc.target = rpm
+	fc.mu.Unlock()
+}
+
+// Duty returns the duty cycle last set.
+func (fc *FanController) Duty() float64 {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.duty
+}
+
+// Start runs the loop.
+func (fc *FanController) Start() error {
+	if fc.Interval <= 0 || fc.MinDuty < 0 || fc.MaxDuty > 1 ||
+		fc.MinDuty > fc.MaxDuty {
+		return fmt.Errorf("fan controller: invalid interval or duty range")
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.stop != nil {
+		return fmt.Errorf("fan controller: already started")
+	}
+	fc.stop, fc.done, fc.err = make(chan struct{}), make(chan struct{}), nil
+	go fc.run(fc.stop, fc.done)
+	return nil
+}
+
+// Stop ends the loop, leaving the fan at the last duty cycle, and returns
+// the output error that ended it early, if any.
+func (fc *FanController) Stop() error {
+	fc.mu.Lock()
+	stop, done := fc.stop, fc.done
+	fc.stop = nil
+	fc.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.err
+}
+
+func (fc *FanController) run(stop, done chan struct{}) {
+	defer close(done)
+	t := time.NewTicker(fc.Interval)
+	defer t.Stop()
+	for {
+		if err := fc.step(fc.Interval.Seconds()); err != nil {
+			fc.mu.Lock()
+			fc.err = err
+			fc.mu.Unlock()
+			return
+		}
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// step sets the duty for the error of the last dt seconds. The integral
+// only grows while the duty isn't pinned at a limit, so it doesn't wind up.
+func (fc *FanController) step(dt float64) error {
+	fc.mu.Lock()
+	target := fc.target
+	fc.mu.Unlock()
+	if fc.Target != nil {
+		target = fc.Target()
+	}
+	e := target - fc.Tach.RPM()
+	fc.mu.Lock()
+	integral := fc.integral + e*dt
+	duty := fc.Kp*e + fc.Ki*integral
+	switch {
+	case duty > fc.MaxDuty:
+		duty = fc.MaxDuty
+	case duty < fc.MinDuty:
+		duty = fc.MinDuty
+	default:
+		fc.integral = integral
+	}
+	fc.duty = duty
+	fc.mu.Unlock()
+	return fc.Out.SetDutyCycle(duty)
+}
@@ -1 +1,5 @@
 module github.com/platinasystems/gpio
+
+go 1.27.1
+
+require github.com/platinasystems/fdt v1.0.1
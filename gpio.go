@@ -7,10 +7,22 @@
 package gpio
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/user"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/platinasystems/fdt"
 )
@@ -19,26 +31,145 @@ type Pin struct {
 	Gpio    int
 	Name    string
 	Default string
+	// Backend carrying out the pin's operations; nil selects the package
+	// backend, see SetBackend.
+	Backend Backend
+	// Device tree label of the pin; its name if the node has no label.
+	Label string
+	// Path of the device tree node the pin was derived from, if any.
+	NodePath string
+	// Kernel name of the pin's line, from gpio-line-names or the driver.
+	LineName string
+	// Consumer label of the pin's character device line requests; the
+	// package's, see SetConsumer, if empty.
+	Consumer string
+	// The pin is asserted when its line is low, so its values and the
+	// levels of "low" and "high" directions are the inverse of the
+	// line's. Change it with SetActiveLow once the pin is in use.
+	ActiveLow bool
+	// Pull resistor of the line, applied by SetBias and whenever the
+	// pin's line is configured.
+	Bias Bias
+	// How the pin drives its line as an output.
+	Drive Drive
+	// Time an input's level must hold for an edge to be reported.
+	Debounce time.Duration
+
+	// Attributes as read by the last Refresh, guarded by mu.
+	Live        Attrs
+	LastRefresh time.Time
+
+	// Outcome of the last operation and count of failed ones, guarded by
+	// mu.
+	err  error
+	nerr int
+	// Last known direction, "in" or "out", and the number of times this
+	// process has changed it; guarded by mu.
+	dir        string
+	dirChanges int
+	// Last value this process wrote, if any, and the counts of the writes
+	// that changed it and of edges delivered to watches; guarded by mu.
+	wrote, written bool
+	toggles, edges uint64
+	// Edges kept for History; guarded by mu.
+	hist edgeRing
+	// Character device line request held by the pin and the flags it was
+	// made with; guarded by mu.
+	line      *os.File
+	lineFlags uint64
+	// Watch of the pin by one of the builtin backends; guarded by mu.
+	watch *watcher
+	// Sysfs value attribute kept open by Hold; guarded by mu.
+	value *os.File
+	// Lock file flocked by TryClaim; guarded by mu.
+	lock *os.File
+	// Time of the pin's last sysfs export, after which opens retry for
+	// the export timeout; guarded by mu.
+	exportedAt time.Time
+	// Serializes the pin's backend operations, making its methods safe
+	// for concurrent use.
+	op sync.Mutex
+}
+
+// Attrs are the live attributes of an exported pin, as read by Refresh.
+type Attrs struct {
+	Direction string
+	Value     bool
+	Edge      string
+	ActiveLow bool
 }
 
 type GpioAliasMap map[string]string
 type PinMap map[string]*Pin
 
-type Chip struct {
-	// Chip has GPIOs base through base + count.
-	Base, Count Pin
-	// Value of compatible=XXX node in DTS file for this GPIO chip.
-	Compatible map[string]bool
+// PinErrors collects the per pin failures of an operation applied to many
+// pins.
+type PinErrors map[*Pin]error
+
+func (e PinErrors) Error() string {
+	s := make([]string, 0, len(e))
+	for p, err := range e {
+		if pe, f := err.(*PinError); f && pe.Pin == p {
+			s = append(s, err.Error())
+		} else {
+			s = append(s, fmt.Sprintf("%s: %s", p.Name, err))
+		}
+	}
+	sort.Strings(s)
+	return strings.Join(s, "; ")
 }
 
 var aliases GpioAliasMap
 var pins PinMap
 
+// Pins by device tree label where that differs from the pin's name.
+var labels PinMap
+
+// Pins by kernel line name where that differs from the pin's name,
+// including pins of named lines that aren't configured.
+var lineNames PinMap
+
+// Guards the aliases, pins, labels and lineNames maps, and initErrors,
+// GpioBankToBase and bankDevs as the pin map is built.
+var regMu sync.RWMutex
+
+var initOnce sync.Once
+
+// The device tree and the full path and parent of each of its nodes, for
+// the duration of gpioInit.
+var initTree *fdt.Tree
+var nodePaths map[*fdt.Node]string
+var nodeParents map[*fdt.Node]*fdt.Node
+
+// Problems found while building the pin map.
+var initErrors []error
+
+// initError notes a problem found while building the pin map.
+func initError(err error) {
+	regMu.Lock()
+	initErrors = append(initErrors, err)
+	regMu.Unlock()
+}
+
+// Whether the pin map being built is of another system, so its pins are
+// neither exported nor configured.
+var initOffline bool
+
+// Package mutex.
+var mu sync.Mutex
+
+// Pins exported by this process, guarded by mu.
+var exported = make(map[*Pin]bool)
+
 // File prefix for testing w/o proper sysfs.
 var prefix string
 
 func SetDebugPrefix(p string) { prefix = p }
 
+// GpioBankToBase gives the global gpio number of the first line of each
+// bank, by device tree alias. Building the pin map replaces the entries of
+// banks whose controller is found among the kernel's gpiochips; the table
+// is what's left for the others.
 var GpioBankToBase = map[string]int{
 	"gpio0": 0,
 	"gpio1": 32,
@@ -49,6 +180,14 @@ var GpioBankToBase = map[string]int{
 	"gpio6": 192,
 }
 
+// bankBase returns the GpioBankToBase entry of the bank.
+func bankBase(bank string) (base int, found bool) {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	base, found = GpioBankToBase[bank]
+	return
+}
+
 var GpioPinMode = map[string]string{
 	"output-high": "high",
 	"output-low":  "low",
@@ -56,40 +195,327 @@ var GpioPinMode = map[string]string{
 }
 
 func (p *Pin) Export() (err error) {
-	fn := prefix + "/sys/class/gpio/export"
-	f, err := os.OpenFile(fn, os.O_WRONLY, 0)
-	if err != nil {
-		return
+	defer p.record("export", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	if err = p.backend().Export(p); err == nil {
+		mu.Lock()
+		exported[p] = true
+		mu.Unlock()
+		p.log(slog.LevelInfo, "gpio exported")
 	}
-	defer f.Close()
-	fmt.Fprintf(f, "%d\n", p.Gpio)
 	return
 }
 
+// Unexport stops any watch of the pin and releases it back to the kernel.
+func (p *Pin) Unexport() (err error) {
+	defer p.record("unexport", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	p.stopWatch()
+	if h, f := p.backend().(holder); f {
+		h.release(p)
+	}
+	if err = p.backend().Unexport(p); err == nil {
+		mu.Lock()
+		delete(exported, p)
+		mu.Unlock()
+		p.log(slog.LevelInfo, "gpio unexported")
+	}
+	return
+}
+
+// holder is implemented by backends that open the pin for each operation
+// unless held.
+type holder interface {
+	hold(p *Pin) error
+	release(p *Pin) error
+}
+
+// Hold keeps the pin's value open between operations, for reading and
+// writing it at high rates, until Release. The character device always
+// holds its lines, so this is only of use with sysfs.
+func (p *Pin) Hold() (err error) {
+	defer p.record("hold", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	if h, f := p.backend().(holder); f {
+		err = h.hold(p)
+	}
+	return
+}
+
+// Release closes what Hold kept open.
+func (p *Pin) Release() (err error) {
+	defer p.record("release", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	if h, f := p.backend().(holder); f {
+		err = h.release(p)
+	}
+	return
+}
+
+// Cleanup unexports every pin that this process exported, for an orderly
+// shutdown or test teardown.
+func Cleanup() error {
+	mu.Lock()
+	l := make([]*Pin, 0, len(exported))
+	for p := range exported {
+		l = append(l, p)
+	}
+	mu.Unlock()
+	errs := make(PinErrors)
+	for _, p := range l {
+		if err := p.Unexport(); err != nil {
+			errs[p] = err
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// ExportReport sorts the pins of ExportAllReport by outcome.
+type ExportReport struct {
+	// Pins exported by this call.
+	Exported []*Pin
+	// Pins that were already exported, e.g. left behind by another process.
+	AlreadyExported []*Pin
+	Failed          PinErrors
+}
+
+func (r *ExportReport) String() string {
+	return fmt.Sprintf("%d exported, %d already exported, %d failed",
+		len(r.Exported), len(r.AlreadyExported), len(r.Failed))
+}
+
+// ExportAllReport exports every configured pin that isn't already. The
+// kernel refusing an export with EBUSY is taken to mean the pin was already
+// exported.
+func ExportAllReport() *ExportReport {
+	r := &ExportReport{Failed: make(PinErrors)}
+	for _, p := range SortedPins() {
+		if p.IsExported() {
+			r.AlreadyExported = append(r.AlreadyExported, p)
+			continue
+		}
+		err := p.Export()
+		switch {
+		case err == nil:
+			r.Exported = append(r.Exported, p)
+		case errors.Is(err, syscall.EBUSY):
+			r.AlreadyExported = append(r.AlreadyExported, p)
+		default:
+			r.Failed[p] = err
+		}
+	}
+	return r
+}
+
+// ExportNamed exports the pin then links /sys/class/gpio/<name> to its
+// gpioN directory so that scripts may address it by name.
+//
+// Stock sysfs doesn't permit creating links in /sys/class/gpio, so this only
+// works where that directory has been made writable (e.g. by an overlay or a
+// test prefix). If the link can't be made the pin remains exported and the
+// *os.LinkError is returned for the caller to ignore or report.
+func (p *Pin) ExportNamed() (err error) {
+	if !p.IsExported() {
+		if err = p.Export(); err != nil {
+			return
+		}
+	}
+	dir := prefix + "/sys/class/gpio/"
+	target := fmt.Sprintf("gpio%d", p.Gpio)
+	if t, e := os.Readlink(dir + p.Name); e == nil && t == target {
+		return
+	}
+	return os.Symlink(target, dir+p.Name)
+}
+
 func (p *Pin) IsExported() (x bool) {
-	fn := fmt.Sprintf(prefix+"/sys/class/gpio/gpio%d/value", p.Gpio)
-	_, err := os.Stat(fn)
-	if err != nil {
-		return false
+	p.op.Lock()
+	defer p.op.Unlock()
+	return p.backend().IsExported(p)
+}
+
+// Bounds of the delay between checks for sysfs attributes to appear after
+// an export, and how long after it they may take.
+var (
+	exportBackoffInitial, exportBackoffMax = 10 * time.Millisecond,
+		10 * time.Millisecond
+	exportTimeout = time.Second
+)
+
+// SetExportBackoff sets the schedule of WaitExported's checks and of the
+// retries of opens after an export: the first delay is initial and each
+// following delay is double the last up to max. The default is a fixed
+// 10ms.
+func SetExportBackoff(initial, max time.Duration) {
+	if max < initial {
+		max = initial
 	}
-	return true
+	mu.Lock()
+	exportBackoffInitial, exportBackoffMax = initial, max
+	mu.Unlock()
 }
 
+// WaitExported polls for up to timeout for the pin's sysfs attributes, which
+// the kernel and udev may create some time after the export request.
+func (p *Pin) WaitExported(timeout time.Duration) error {
+	mu.Lock()
+	delay, max := exportBackoffInitial, exportBackoffMax
+	mu.Unlock()
+	deadline := time.Now().Add(timeout)
+	for !p.IsExported() {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return fmt.Errorf("%s: not exported after %v", p, timeout)
+		}
+		if delay > left {
+			delay = left
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
+	return nil
+}
+
+// pinOfGpio returns the configured pin of the global gpio, or if there isn't
+// one, an unnamed pin "gpio<N>".
+func pinOfGpio(gpio int) *Pin {
+	for _, p := range pinList() {
+		if p.Gpio == gpio && p.onChip() {
+			return p
+		}
+	}
+	return &Pin{Gpio: gpio, Name: fmt.Sprintf("gpio%d", gpio)}
+}
+
+// WaitForExternalExport waits for up to timeout for another process to
+// export the given gpio. It returns the registered pin of that number or,
+// if there isn't one, an unnamed pin.
+func WaitForExternalExport(gpio int, timeout time.Duration) (*Pin, error) {
+	p := pinOfGpio(gpio)
+	if err := p.WaitExported(timeout); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetExportTimeout sets how long after a sysfs export the pin's attributes
+// may take to appear, with the permissions udev gives them, default 1s.
+// Until then opens that fail for want of them are retried with the export
+// backoff, and Export fails if they don't appear; 0 disables the retries.
+func SetExportTimeout(d time.Duration) {
+	mu.Lock()
+	exportTimeout = d
+	mu.Unlock()
+}
+
+// The group given the exported pins' attributes, or -1 for none.
+var exportGID = -1
+
+// SetExportGroup has the sysfs attributes of pins exported from now on,
+// once they appear, given to the named group with group read and write
+// permission, so that daemons in the group can use the pins without root.
+// It only takes effect when running as root; "" sets no group, the default.
+func SetExportGroup(name string) error {
+	gid := -1
+	if name != "" {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return fmt.Errorf("group %s: gid %q: %v", name, g.Gid, err)
+		}
+	}
+	mu.Lock()
+	exportGID = gid
+	mu.Unlock()
+	return nil
+}
+
+// Open opens the sysfs attribute of the exported pin for reading and
+// writing, retrying a missing or forbidden attribute for the export timeout
+// after the export.
 func (p *Pin) Open(name string) (f *os.File, fn string, err error) {
 	fn = fmt.Sprintf(prefix+"/sys/class/gpio/gpio%d/%s", p.Gpio, name)
-	f, err = os.OpenFile(fn, os.O_RDWR, 0)
-	return
+	mu.Lock()
+	delay, max := exportBackoffInitial, exportBackoffMax
+	deadline := p.exportedAt.Add(exportTimeout)
+	mu.Unlock()
+	for {
+		f, err = os.OpenFile(fn, os.O_RDWR, 0)
+		if err == nil || !(errors.Is(err, os.ErrNotExist) ||
+			errors.Is(err, os.ErrPermission)) {
+			return
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			return
+		}
+		if delay > left {
+			delay = left
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > max {
+			delay = max
+		}
+	}
 }
 
 func (p *Pin) Direction() (dir string, err error) {
-	f, _, err := p.Open("direction")
-	if err != nil {
-		return
+	defer p.record("direction", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	dir, err = p.backend().Direction(p)
+	if err == nil {
+		mu.Lock()
+		p.dir = dir
+		mu.Unlock()
 	}
-	defer f.Close()
-
-	_, err = fmt.Fscanf(f, "%s\n", &dir)
+	return
+}
 
+// Directions reads the direction of each of the given pins with a single
+// open, read and close per pin. Pins that can't be read are left out of the
+// returned map and reported in a PinErrors.
+func Directions(pins []*Pin) (m map[*Pin]string, err error) {
+	m = make(map[*Pin]string, len(pins))
+	errs := make(PinErrors)
+	buf := make([]byte, 8)
+	for _, p := range pins {
+		if _, f := p.backend().(sysfsBackend); !f {
+			if dir, e := p.Direction(); e != nil {
+				errs[p] = e
+			} else {
+				m[p] = dir
+			}
+			continue
+		}
+		fn := fmt.Sprintf(prefix+"/sys/class/gpio/gpio%d/direction", p.Gpio)
+		f, e := os.Open(fn)
+		if e != nil {
+			errs[p] = e
+			continue
+		}
+		n, e := f.Read(buf)
+		f.Close()
+		if e != nil {
+			errs[p] = e
+			continue
+		}
+		m[p] = strings.TrimSpace(string(buf[:n]))
+	}
+	if len(errs) != 0 {
+		err = errs
+	}
 	return
 }
 
@@ -99,44 +525,295 @@ func (p *Pin) Direction() (dir string, err error) {
 // 	operation, values "low" and "high" may be written to
 // 	configure the GPIO as an output with that initial value.
 func (p *Pin) SetDirection(dir string) (err error) {
-	f, _, err := p.Open("direction")
+	defer p.record("set direction", &err)
+	switch dir {
+	case "in", "out", "low", "high":
+	default:
+		return fmt.Errorf("%w %q", ErrBadDirection, dir)
+	}
+	p.op.Lock()
+	defer p.op.Unlock()
+	err = p.backend().SetDirection(p, dir)
+	if err == nil {
+		p.noteDirection(dir)
+		p.log(slog.LevelDebug, "gpio direction set", "direction", dir)
+		if dir == "high" || dir == "low" {
+			p.noteWrite(dir == "high", false)
+			p.audit("set direction", dir == "high")
+		}
+	}
+	return
+}
+
+// noteDirection caches a direction that has been set.
+func (p *Pin) noteDirection(dir string) {
+	if dir != "in" {
+		dir = "out"
+	}
+	mu.Lock()
+	if p.dir != "" && p.dir != dir {
+		p.dirChanges++
+	}
+	p.dir = dir
+	mu.Unlock()
+}
+
+// DirectionChanges returns the number of times SetDirection has switched
+// the pin between input and output, as far as this process knows; changes
+// made by others and the initial configuration aren't counted.
+func (p *Pin) DirectionChanges() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return p.dirChanges
+}
+
+// AdoptCurrentValue takes over a pin that another owner may be driving by
+// making it an output at its present level with a "high" or "low" direction
+// write, so the line doesn't glitch to the "out" default of low.
+//
+// The level is read then written in separate operations; should the other
+// owner change the pin in between, the stale level is the one adopted.
+func (p *Pin) AdoptCurrentValue() (err error) {
+	v, err := p.Value()
 	if err != nil {
 		return
 	}
-	defer f.Close()
+	dir := "low"
+	if v {
+		dir = "high"
+	}
+	return p.SetDirection(dir)
+}
 
-	_, err = fmt.Fprintf(f, "%s\n", dir)
-	return
+// SafeInput releases the pin to high impedance by making it an input.
+func (p *Pin) SafeInput() error {
+	return p.SetDirection("in")
+}
+
+// SafeAllInputs makes every pin that's currently an output an input. It's
+// meant for deferred and panic handlers: pins keep their last state after
+// the program exits, so this is how to let go of actuators on the way out.
+func SafeAllInputs() error {
+	errs := make(PinErrors)
+	for _, p := range pinList() {
+		dir, err := p.Direction()
+		if err == nil && dir == "out" {
+			err = p.SafeInput()
+		}
+		if err != nil {
+			errs[p] = err
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
 }
 
 func (p *Pin) SetValue(v bool) (err error) {
-	f, _, err := p.Open("value")
+	defer p.record("set value", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	if err = p.backend().Write(p, v); err == nil {
+		p.noteWrite(v, false)
+		p.log(slog.LevelDebug, "gpio written", "value", v)
+		p.audit("set value", v)
+	}
+	return
+}
+
+// Toggle inverts the pin's value.
+func (p *Pin) Toggle() (err error) {
+	defer p.record("toggle", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	v, err := p.backend().Read(p)
 	if err != nil {
 		return
 	}
-	defer f.Close()
-	x := 0
-	if v {
-		x = 1
+	if err = p.backend().Write(p, !v); err == nil {
+		p.noteWrite(!v, true)
+		p.log(slog.LevelDebug, "gpio written", "value", !v)
+		p.audit("toggle", !v)
 	}
-	_, err = fmt.Fprintf(f, "%d\n", x)
 	return
 }
 
-func (p *Pin) Value() (v bool, err error) {
-	f, _, err := p.Open("value")
+// Pulse sets the pin's value to level for width then back to what it was,
+// e.g. to strobe a reset. Other operations on the pin wait for the pulse to
+// end.
+func (p *Pin) Pulse(width time.Duration, level bool) (err error) {
+	defer p.record("pulse", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	v, err := p.backend().Read(p)
 	if err != nil {
 		return
 	}
-	defer f.Close()
-	x := 0
-	_, err = fmt.Fscanf(f, "%d\n", &x)
-	if x != 0 {
-		v = true
+	if err = p.backend().Write(p, level); err != nil {
+		return
+	}
+	p.noteWrite(level, level != v)
+	p.log(slog.LevelDebug, "gpio pulsed", "value", level, "width", width)
+	p.audit("pulse", level)
+	time.Sleep(width)
+	if err = p.backend().Write(p, v); err == nil {
+		p.noteWrite(v, level != v)
+		p.audit("pulse", v)
+	}
+	return
+}
+
+// SetActiveLow sets whether the pin is asserted low and, if the pin is
+// in use, has the backend apply the new polarity.
+func (p *Pin) SetActiveLow(on bool) (err error) {
+	defer p.record("set active low", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	p.ActiveLow = on
+	if x, f := p.backend().(polaritySetter); f {
+		err = x.setActiveLow(p)
 	}
 	return
 }
 
+// SetValueConfirmed writes v then reads it back, rewriting up to retries
+// more times until the readback matches.
+//
+// The readback is the line's level, not the written value, so this can't
+// confirm a high on an open-drain or otherwise shared line where another
+// driver may be holding it low; just use SetValue for those.
+func (p *Pin) SetValueConfirmed(v bool, retries int) error {
+	for i := 0; ; i++ {
+		if err := p.SetValue(v); err != nil {
+			return err
+		}
+		x, err := p.Value()
+		if err != nil {
+			return err
+		}
+		if x == v {
+			return nil
+		}
+		if i >= retries {
+			return fmt.Errorf("%s: value didn't read back as %v after %d writes",
+				p, v, i+1)
+		}
+	}
+}
+
+func (p *Pin) Value() (v bool, err error) {
+	defer p.record("value", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	return p.backend().Read(p)
+}
+
+// Refresh rereads the pin's direction, value, edge and active_low through
+// its backend into Live and stamps LastRefresh; backends that can't read
+// back the edge and polarity leave the Edge and report the pin's ActiveLow.
+// Attributes that can't be read keep their previous value and their errors
+// are returned together.
+func (p *Pin) Refresh() (err error) {
+	defer p.record("refresh", &err)
+	p.op.Lock()
+	defer p.op.Unlock()
+	var errs errorList
+	b := p.backend()
+	dir, e := b.Direction(p)
+	if e != nil {
+		errs = append(errs, e)
+	}
+	value, e := b.Read(p)
+	valueOK := e == nil
+	if e != nil {
+		errs = append(errs, e)
+	}
+	edge, activeLow, activeLowOK := "", p.ActiveLow, true
+	if x, f := b.(attrsReader); f {
+		if edge, e = x.edge(p); e != nil {
+			errs = append(errs, e)
+		}
+		if activeLow, e = x.activeLow(p); e != nil {
+			errs = append(errs, e)
+			activeLowOK = false
+		}
+	}
+	mu.Lock()
+	if dir != "" {
+		p.Live.Direction = dir
+	}
+	if valueOK {
+		p.Live.Value = value
+	}
+	if edge != "" {
+		p.Live.Edge = edge
+	}
+	if activeLowOK {
+		p.Live.ActiveLow = activeLow
+	}
+	p.LastRefresh = time.Now()
+	mu.Unlock()
+	return errs.err()
+}
+
+// errorList combines the failures of the steps of a single operation.
+type errorList []error
+
+func (l errorList) Error() string {
+	s := make([]string, len(l))
+	for i, err := range l {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+func (l errorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// record wraps the failure of the named operation in a PinError and notes
+// the outcome for FailedPins and WriteMetrics.
+func (p *Pin) record(op string, err *error) {
+	if *err != nil {
+		if pe, f := (*err).(*PinError); !f || pe.Pin != p {
+			*err = &PinError{Pin: p, Op: op, Err: *err}
+		}
+	}
+	mu.Lock()
+	p.err = *err
+	if *err != nil {
+		p.nerr++
+	}
+	mu.Unlock()
+}
+
+// FailedPins returns the configured pins whose last operation failed along
+// with that operation's error.
+func FailedPins() map[*Pin]error {
+	l := pinList()
+	m := make(map[*Pin]error)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range l {
+		if p.err != nil {
+			m[p] = p.err
+		}
+	}
+	return m
+}
+
+// SameLine reports whether the two pins, whatever their names, are the same
+// global gpio of the same backend. Backends of a type that isn't comparable
+// are never the same, so those with state should be pointers.
+func (p *Pin) SameLine(other *Pin) bool {
+	return p != nil && other != nil && p.Gpio == other.Gpio &&
+		sameBackend(p.backend(), other.backend())
+}
+
 func (p *Pin) String() string {
 	return fmt.Sprintf("Gpio: %d (%s)", p.Gpio, p.Name)
 }
@@ -145,45 +822,371 @@ func (p *Pin) SetDefault() (err error) {
 	return p.SetDirection(p.Default)
 }
 
+// SetAllDefaults applies the default direction of every pin that has one.
+func SetAllDefaults() error {
+	return SetAllDefaultsConcurrent(1)
+}
+
+// SetAllDefaultsConcurrent is SetAllDefaults with the pins spread across the
+// given number of workers, or GOMAXPROCS workers if that's less than one.
+// Each pin has its own sysfs attribute so the writes don't interfere.
+func SetAllDefaultsConcurrent(workers int) error {
+	errs := make(PinErrors)
+	for p, err := range applyDefaults(nil, workers) {
+		if err != nil {
+			errs[p] = err
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// ApplyDefaults applies the default direction of every pin that has one and
+// that filter, if not nil, accepts, carrying on past failures. It returns
+// the outcome of each pin tried, nil for those set.
+func ApplyDefaults(filter func(*Pin) bool) map[*Pin]error {
+	return applyDefaults(filter, 1)
+}
+
+func applyDefaults(filter func(*Pin) bool, workers int) map[*Pin]error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[*Pin]error)
+	)
+	c := make(chan *Pin)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range c {
+				err := p.SetDefault()
+				mu.Lock()
+				results[p] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range pinList() {
+		if p.Default != "" && (filter == nil || filter(p)) {
+			c <- p
+		}
+	}
+	close(c)
+	wg.Wait()
+	return results
+}
+
 func NewPin(name, mode, bank, index string) (err error) {
+	gpioInit()
+	_, err = newPin(name, mode, bank, index)
+	return
+}
+
+// newPin is NewPin returning the pin, which is added even if it can't be
+// exported.
+func newPin(name, mode, bank, index string) (p *Pin, err error) {
 	i, _ := strconv.Atoi(index)
-	p := &Pin{Gpio: GpioBankToBase[bank] + i, Name: name,
+	base, _ := bankBase(bank)
+	p = &Pin{Gpio: base + i, Name: name,
 		Default: GpioPinMode[mode]}
+	regMu.Lock()
 	pins[name] = p
-	if p.IsExported() {
+	regMu.Unlock()
+	if initOffline || p.IsExported() {
 		return
 	}
-	return p.Export()
+	err = p.Export()
+	return
+}
+
+// TemplatePins adds and exports count pins with consecutive gpios from
+// baseGpio, named by formatting the pin's index, from 0, with pattern, e.g.
+// "led%d". Nothing is added if the pattern doesn't format the index or a
+// name is already taken.
+func TemplatePins(pattern string, count int, baseGpio int) error {
+	if count <= 0 {
+		return fmt.Errorf("%q: invalid pin count %d", pattern, count)
+	}
+	gpioInit()
+	a, b := fmt.Sprintf(pattern, 0), fmt.Sprintf(pattern, 1)
+	if a == b || strings.Contains(a, "%!") {
+		return fmt.Errorf("%q: pattern doesn't format the pin index",
+			pattern)
+	}
+	names := make([]string, count)
+	l := make([]*Pin, count)
+	regMu.Lock()
+	for i := range names {
+		names[i] = fmt.Sprintf(pattern, i)
+		if _, f := pins[names[i]]; f {
+			regMu.Unlock()
+			return fmt.Errorf("%s: pin already exists", names[i])
+		}
+	}
+	for i, name := range names {
+		l[i] = &Pin{Gpio: baseGpio + i, Name: name}
+		pins[name] = l[i]
+	}
+	regMu.Unlock()
+	errs := make(PinErrors)
+	for _, p := range l {
+		if p.IsExported() {
+			continue
+		}
+		if err := p.Export(); err != nil {
+			errs[p] = err
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
 }
 
+// RegisterPins adds pins made outside the device tree, e.g. an expander's,
+// to the pin map so FindPin finds them. Nothing is added if a name is
+// already taken. Building the pin map again drops them.
+func RegisterPins(l ...*Pin) error {
+	gpioInit()
+	regMu.Lock()
+	defer regMu.Unlock()
+	for _, p := range l {
+		if _, f := pins[p.Name]; f {
+			return fmt.Errorf("%s: pin already exists", p.Name)
+		}
+	}
+	for _, p := range l {
+		pins[p.Name] = p
+	}
+	return nil
+}
+
+// FindPin looks a pin up by name, device tree label or kernel line name.
+// Named lines that aren't configured pins have pins of their own, outside
+// the pin map.
 func FindPin(name string) (p *Pin, f bool) {
 	gpioInit()
-	p, f = pins[name]
+	regMu.RLock()
+	defer regMu.RUnlock()
+	if p, f = pins[name]; !f {
+		if p, f = labels[name]; !f {
+			p, f = lineNames[name]
+		}
+	}
 	return
 }
 
+// SetValue sets the value of the pin FindPin finds by name.
+func SetValue(name string, v bool) error {
+	p, err := lookupPin(name)
+	if err != nil {
+		return err
+	}
+	return p.SetValue(v)
+}
+
+// Value reads the value of the pin FindPin finds by name.
+func Value(name string) (bool, error) {
+	p, err := lookupPin(name)
+	if err != nil {
+		return false, err
+	}
+	return p.Value()
+}
+
+// lookupPin is FindPin failing with ErrNoSuchPin.
+func lookupPin(name string) (*Pin, error) {
+	p, f := FindPin(name)
+	if !f {
+		return nil, fmt.Errorf("%s: %w", name, ErrNoSuchPin)
+	}
+	return p, nil
+}
+
+// FindPins returns the configured pins, in name order, whose names match
+// the pattern, e.g. "fan*_fault" or "psu[12].present". It's a regexp of the
+// whole name if it has any of ^$+|(){}\ or .*, otherwise a glob as for
+// path.Match.
+func FindPins(pattern string) ([]*Pin, error) {
+	match := func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	if strings.ContainsAny(pattern, `^$+|(){}\`) ||
+		strings.Contains(pattern, ".*") {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+		match = regexp.MustCompile("^(?:" + pattern + ")$").MatchString
+	} else if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%q: %v", pattern, err)
+	}
+	var l []*Pin
+	for _, p := range SortedPins() {
+		if match(p.Name) {
+			l = append(l, p)
+		}
+	}
+	return l, nil
+}
+
+// FindPinByNodePath returns the pin derived from the device tree node with
+// the given full path, e.g. "/soc/gpio@18100/led@5".
+func FindPinByNodePath(path string) (p *Pin, f bool) {
+	path = strings.TrimSuffix(path, "/")
+	for _, p = range pinList() {
+		if p.NodePath != "" && p.NodePath == path {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
 func NumPins() int {
 	gpioInit()
+	regMu.RLock()
+	defer regMu.RUnlock()
 	return len(pins)
 }
 
+// AllPins returns a copy of the pin map; use SortedPins, PinNames or
+// EachPin to range over the pins in order.
 func AllPins() (pm PinMap) {
 	gpioInit()
-	return pins
+	regMu.RLock()
+	defer regMu.RUnlock()
+	pm = make(PinMap, len(pins))
+	for name, p := range pins {
+		pm[name] = p
+	}
+	return
 }
 
-func gpioInit() {
-	if aliases != nil {
-		return
+// PinNames returns the names of the configured pins, sorted.
+func PinNames() []string {
+	l := SortedPins()
+	names := make([]string, len(l))
+	for i, p := range l {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// EachPin calls f with each configured pin in name order, stopping at and
+// returning the first error. The pins are those configured at the call;
+// f may add or remove pins.
+func EachPin(f func(p *Pin) error) error {
+	for _, p := range SortedPins() {
+		if err := f(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinList returns the configured pins in no particular order.
+func pinList() []*Pin {
+	gpioInit()
+	regMu.RLock()
+	defer regMu.RUnlock()
+	l := make([]*Pin, 0, len(pins))
+	for _, p := range pins {
+		l = append(l, p)
 	}
+	return l
+}
+
+// SortedPins returns the configured pins ordered by name.
+func SortedPins() []*Pin {
+	l := pinList()
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+// ConfigFingerprint returns the hex SHA-256 of the name, gpio and default of
+// each pin, and the backend of those off chip, in name order, so the same
+// configuration always hashes the same.
+func ConfigFingerprint() string {
+	h := sha256.New()
+	for _, p := range SortedPins() {
+		if p.onChip() {
+			fmt.Fprintf(h, "%q %d %q\n", p.Name, p.Gpio, p.Default)
+		} else {
+			fmt.Fprintf(h, "%q %s %d %q\n", p.Name,
+				backendName(p.Backend), p.Gpio, p.Default)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func gpioInit() {
+	initOnce.Do(loadTree)
+}
+
+func loadTree() {
+	buildRegistry(fdt.DefaultTree(), false)
+}
+
+// buildRegistry replaces the pin map with that of the given tree, which may
+// be nil for none, of this system unless offline.
+func buildRegistry(t *fdt.Tree, offline bool) {
+	initOffline = offline
+	defer func() { initOffline = false }()
+	regMu.Lock()
 	aliases = make(GpioAliasMap)
 	pins = make(PinMap)
-
-	t := fdt.DefaultTree()
+	labels = make(PinMap)
+	lineNames = make(PinMap)
+	pwms = make(map[string]*Pwm)
+	initErrors = nil
+	bankDevs = make(map[string]string)
+	regMu.Unlock()
+	board = ""
+	resetMmapChips()
 
 	if t != nil {
+		initTree = t
+		nodePaths = make(map[*fdt.Node]string)
+		nodeParents = make(map[*fdt.Node]*fdt.Node)
+		controllers = make(map[uint32]controller)
+		gatherNodePaths(t.RootNode, "")
 		t.MatchNode("aliases", gatherAliases)
 		t.EachProperty("gpio-controller", "", gatherPins)
+		t.EachProperty("compatible", "gpio-", gatherLedsKeys)
+		t.EachProperty("pwms", "", gatherPwms)
+		gatherBoard(t)
+		initTree, nodePaths, nodeParents = nil, nil, nil
+		controllers = nil
+	}
+	if !offline {
+		gatherChipLineNames()
+	}
+}
+
+// InitErrors returns the problems found in the device tree while building
+// the pin map.
+func InitErrors() []error {
+	gpioInit()
+	regMu.RLock()
+	defer regMu.RUnlock()
+	return append([]error(nil), initErrors...)
+}
+
+func gatherNodePaths(n *fdt.Node, parent string) {
+	path := "/"
+	if parent != "" {
+		path = strings.TrimSuffix(parent, "/") + "/" + n.Name
+	}
+	nodePaths[n] = path
+	for _, c := range n.Children {
+		nodeParents[c] = n
+		gatherNodePaths(c, path)
 	}
 }
 
@@ -204,8 +1207,27 @@ func gatherPins(n *fdt.Node, name string, value string) {
 
 	for na, al := range aliases {
 		if al == n.Name {
+			if !initOffline {
+				discoverBank(n, na)
+			}
+			if _, f := bankBase(na); !f {
+				initError(fmt.Errorf("%s: no gpiochip for bank %s",
+					nodePaths[n], na))
+				continue
+			}
+			gatherMmapChip(n, na)
+			for _, prop := range []string{"phandle", "linux,phandle"} {
+				if b, f := n.Properties[prop]; f && len(b) == 4 {
+					controllers[initTree.PropUint32(b)] = controller{n, na}
+				}
+			}
 			for _, c := range n.Children {
+				if _, f := c.Properties["gpio-hog"]; f {
+					gatherHog(n, c, na)
+					continue
+				}
 				mode := ""
+				pn = nil
 				for p, _ := range c.Properties {
 					switch p {
 					case "gpio-pin-desc":
@@ -214,12 +1236,187 @@ func gatherPins(n *fdt.Node, name string, value string) {
 						mode = p
 					}
 				}
-				err := NewPin(pn[0], mode, na, pn[1])
+				if pn == nil {
+					continue
+				}
+				if len(pn) != 2 {
+					initError(fmt.Errorf("%s/%s: malformed gpio-pin-desc",
+						nodePaths[n], c.Name))
+					continue
+				}
+				p, err := newPin(pn[0], mode, na, pn[1])
+				p.NodePath = nodePaths[n] + "/" + c.Name
+				p.Label = p.Name
+				if b, f := c.Properties["label"]; f && len(b) > 1 {
+					p.Label = initTree.PropString(b)
+					if p.Label != p.Name {
+						regMu.Lock()
+						labels[p.Label] = p
+						regMu.Unlock()
+					}
+				}
+				if _, f := c.Properties["active-low"]; f {
+					if e := p.initActiveLow(); e != nil {
+						initError(fmt.Errorf("%s: %s", p.NodePath, e))
+					}
+				}
+				p.initBias(nodeBias(c))
+				p.initDrive(nodeDrive(c))
 				if err != nil {
-					fmt.Printf("Error setting %s to %s: %s\n",
-						pn[0], mode, err)
+					p.log(slog.LevelError, "gpio init failed",
+						"mode", mode, "err", err)
 				}
 			}
+			gatherLineNames(n, na)
+		}
+	}
+}
+
+// Add pins for the lines of a gpio-hog node; these go by the node's
+// line-name, or failing that its name, with the hogged state as default.
+// The kernel keeps the lines it hogs, so a line that's busy is left as the
+// kernel set it rather than reported.
+func gatherHog(n, c *fdt.Node, bank string) {
+	path := nodePaths[n] + "/" + c.Name
+	mode := ""
+	for _, m := range []string{"output-high", "output-low", "input"} {
+		if _, f := c.Properties[m]; f {
+			mode = m
 		}
 	}
+	if mode == "" {
+		initError(fmt.Errorf("%s: gpio-hog without a state", path))
+		return
+	}
+	ncells := 2
+	if b, f := n.Properties["#gpio-cells"]; f && len(b) == 4 {
+		ncells = int(initTree.PropUint32(b))
+	}
+	cells := initTree.PropUint32Slice(c.Properties["gpios"])
+	if ncells == 0 || len(cells) == 0 || len(cells)%ncells != 0 {
+		initError(fmt.Errorf("%s: malformed gpios", path))
+		return
+	}
+	name := strings.Split(c.Name, "@")[0]
+	if b, f := c.Properties["line-name"]; f && len(b) > 1 {
+		name = initTree.PropString(b)
+	}
+	for i := 0; i < len(cells); i += ncells {
+		pn := name
+		if len(cells) > ncells {
+			pn = fmt.Sprintf("%s.%d", name, i/ncells)
+		}
+		p, err := newPin(pn, mode, bank, strconv.Itoa(int(cells[i])))
+		p.NodePath = path
+		p.Label = pn
+		if errors.Is(err, ErrBusy) {
+			continue
+		}
+		// Bit 0 of the flags cell is GPIO_ACTIVE_LOW.
+		if err == nil && ncells > 1 && cells[i+1]&1 != 0 {
+			err = p.initActiveLow()
+		}
+		b := nodeBias(c)
+		if b == BiasAsIs && ncells > 1 {
+			b = flagsBias(cells[i+1])
+		}
+		d := nodeDrive(c)
+		if d == DrivePushPull && ncells > 1 {
+			d = flagsDrive(cells[i+1])
+		}
+		if err == nil {
+			p.initBias(b)
+			p.initDrive(d)
+		}
+		if err != nil {
+			initError(fmt.Errorf("%s: %s: %s", path, pn, err))
+		}
+	}
+}
+
+// nodeBias returns the bias given by a pin node's bias-* property.
+func nodeBias(c *fdt.Node) Bias {
+	for b, prop := range map[Bias]string{
+		BiasPullUp:   "bias-pull-up",
+		BiasPullDown: "bias-pull-down",
+		BiasDisable:  "bias-disable",
+	} {
+		if _, f := c.Properties[prop]; f {
+			return b
+		}
+	}
+	return BiasAsIs
+}
+
+// flagsBias returns the bias of a gpio specifier's GPIO_PULL_* flags.
+func flagsBias(flags uint32) Bias {
+	switch {
+	case flags&16 != 0:
+		return BiasPullUp
+	case flags&32 != 0:
+		return BiasPullDown
+	case flags&64 != 0:
+		return BiasDisable
+	}
+	return BiasAsIs
+}
+
+func (p *Pin) initActiveLow() error {
+	if initOffline {
+		p.ActiveLow = true
+		return nil
+	}
+	return p.SetActiveLow(true)
+}
+
+// initBias applies a device tree bias; without a backend able to apply it,
+// it's just kept for when the pin switches to one.
+func (p *Pin) initBias(b Bias) {
+	if b == BiasAsIs {
+		return
+	}
+	if initOffline {
+		p.Bias = b
+		return
+	}
+	err := p.SetBias(b)
+	if err != nil && !errors.Is(err, ErrBiasUnsupported) {
+		initError(fmt.Errorf("%s: %s", p.NodePath, err))
+	}
+}
+
+// nodeDrive returns the drive given by a pin node's drive-* property.
+func nodeDrive(c *fdt.Node) Drive {
+	if _, f := c.Properties["drive-open-drain"]; f {
+		return DriveOpenDrain
+	}
+	if _, f := c.Properties["drive-open-source"]; f {
+		return DriveOpenSource
+	}
+	return DrivePushPull
+}
+
+// flagsDrive returns the drive of a gpio specifier's GPIO_SINGLE_ENDED and
+// GPIO_LINE_OPEN_DRAIN flags.
+func flagsDrive(flags uint32) Drive {
+	switch flags & 6 {
+	case 6:
+		return DriveOpenDrain
+	case 2:
+		return DriveOpenSource
+	}
+	return DrivePushPull
+}
+
+func (p *Pin) initDrive(d Drive) {
+	if d == DrivePushPull {
+		return
+	}
+	if initOffline {
+		p.Drive = d
+		return
+	}
+	if err := p.SetDrive(d); err != nil {
+		initError(fmt.Errorf("%s: %s", p.NodePath, err))
+	}
 }
@@ -0,0 +1,343 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/fdt"
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+// cells is a property of 32 bit big endian integers.
+func cells(l ...uint32) []byte {
+	b := make([]byte, 4*len(l))
+	for i, v := range l {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// str is a string property.
+func str(s string) []byte { return []byte(s + "\x00") }
+
+func node(name string, props map[string][]byte, children ...*fdt.Node) *fdt.Node {
+	n := &fdt.Node{Name: name, Properties: props,
+		Children: make(map[string]*fdt.Node)}
+	if n.Properties == nil {
+		n.Properties = make(map[string][]byte)
+	}
+	for _, c := range children {
+		n.Children[c.Name] = c
+	}
+	return n
+}
+
+// bankTree returns a device tree of a gpio0 controller of the given nodes.
+func bankTree(children ...*fdt.Node) *fdt.Tree {
+	return &fdt.Tree{RootNode: node("", nil,
+		node("aliases", map[string][]byte{"gpio0": str("/soc/gpio@0")}),
+		node("soc", nil,
+			node("gpio@0", map[string][]byte{
+				"gpio-controller": nil,
+				"#gpio-cells":     cells(2),
+			}, children...)))}
+}
+
+// busyBackend is a gpiotest backend whose line busy is taken by another.
+type busyBackend struct {
+	*gpiotest.Backend
+	busy int
+}
+
+func (b busyBackend) Export(p *gpio.Pin) error {
+	if p.Gpio == b.busy {
+		return gpio.ErrBusy
+	}
+	return b.Backend.Export(p)
+}
+
+// initTree builds the pin map of the tree as this system's, on the given
+// backend and an empty sysfs tree, restoring the sysfs backend at the end of
+// the test.
+func initTree(t *testing.T, tree *fdt.Tree, be gpio.Backend) {
+	dir, err := ioutil.TempDir("", "gpio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gpio.SetDebugPrefix("")
+		gpio.SetBackend(gpio.Sysfs)
+		os.RemoveAll(dir)
+	})
+	err = gpio.InitFromTree(tree, gpio.WithPrefix(dir),
+		gpio.WithBackend(be))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pin returns the pin FindPin finds by name, failing if there's none.
+func pin(t *testing.T, name string) *gpio.Pin {
+	t.Helper()
+	p, f := gpio.FindPin(name)
+	if !f {
+		t.Fatalf("%s: no such pin", name)
+	}
+	return p
+}
+
+func TestGatherHog(t *testing.T) {
+	be := gpiotest.New()
+	initTree(t, bankTree(
+		node("led-hog", map[string][]byte{
+			"gpio-hog":    nil,
+			"gpios":       cells(5, 0),
+			"output-high": nil,
+			"line-name":   str("sys_led"),
+		}),
+		node("fan-hog", map[string][]byte{
+			"gpio-hog":   nil,
+			"gpios":      cells(6, 1, 7, 0),
+			"output-low": nil,
+		})), be)
+	for _, tt := range []struct {
+		name    string
+		gpio    int
+		def     string
+		lowTrue bool
+	}{
+		{"sys_led", 5, "high", false},
+		{"fan-hog.0", 6, "low", true},
+		{"fan-hog.1", 7, "low", false},
+	} {
+		p := pin(t, tt.name)
+		if p.Gpio != tt.gpio || p.Default != tt.def ||
+			p.ActiveLow != tt.lowTrue || p.Label != tt.name {
+			t.Errorf("%s: gpio %d default %q active low %v label %q",
+				tt.name, p.Gpio, p.Default, p.ActiveLow, p.Label)
+		}
+		if !p.IsExported() {
+			t.Errorf("%s: not exported", tt.name)
+		}
+	}
+	if p := pin(t, "sys_led"); p.NodePath != "/soc/gpio@0/led-hog" {
+		t.Errorf("sys_led: node path %q", p.NodePath)
+	}
+}
+
+func TestGatherHogBusy(t *testing.T) {
+	be := busyBackend{gpiotest.New(), 6}
+	initTree(t, bankTree(
+		node("fan-hog", map[string][]byte{
+			"gpio-hog":   nil,
+			"gpios":      cells(6, 1),
+			"output-low": nil,
+			"line-name":  str("fan_en"),
+		})), be)
+	p := pin(t, "fan_en")
+	if p.IsExported() || p.ActiveLow {
+		t.Errorf("busy line exported %v, active low %v", p.IsExported(),
+			p.ActiveLow)
+	}
+	if ops := be.Ops(); len(ops) != 0 {
+		t.Errorf("busy line ops %v", ops)
+	}
+}
+
+// defaultPins replaces the pin map with n gpiotest pins of varied defaults.
+func defaultPins(tb testing.TB, n int) {
+	gpio.InitFromTree(nil, gpio.WithOffline())
+	be := gpiotest.New()
+	defaults := []string{"in", "out", "low", "high"}
+	l := make([]*gpio.Pin, n)
+	for i := range l {
+		l[i] = &gpio.Pin{Gpio: i, Name: fmt.Sprintf("pin%d", i),
+			Default: defaults[i%len(defaults)], Backend: be}
+	}
+	if err := gpio.RegisterPins(l...); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func BenchmarkSetAllDefaults(b *testing.B) {
+	defaultPins(b, 4096)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := gpio.SetAllDefaults(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The gpiotest backend serializes its lines, so this measures the spread of
+// the pins across the workers rather than concurrent writes.
+func BenchmarkSetAllDefaultsConcurrent(b *testing.B) {
+	defaultPins(b, 4096)
+	for _, workers := range []int{2, 8, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				err := gpio.SetAllDefaultsConcurrent(workers)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestGatherPinsLabel(t *testing.T) {
+	initTree(t, bankTree(
+		node("reset@3", map[string][]byte{
+			"gpio-pin-desc": nil,
+			"output-high":   nil,
+			"label":         str("cpu_reset_l"),
+		})), gpiotest.New())
+	p := pin(t, "reset")
+	if p.Label != "cpu_reset_l" || p.Gpio != 3 || p.Default != "high" {
+		t.Errorf("reset: label %q gpio %d default %q", p.Label, p.Gpio,
+			p.Default)
+	}
+	if l := pin(t, "cpu_reset_l"); l != p {
+		t.Errorf("label finds %v, not the pin %v", l, p)
+	}
+}
+
+func TestGatherPinsNoLabel(t *testing.T) {
+	initTree(t, bankTree(
+		node("present@9", map[string][]byte{
+			"gpio-pin-desc": nil,
+			"input":         nil,
+		})), gpiotest.New())
+	p := pin(t, "present")
+	if p.Label != "present" || p.Gpio != 9 || p.Default != "in" {
+		t.Errorf("present: label %q gpio %d default %q", p.Label, p.Gpio,
+			p.Default)
+	}
+	if n := gpio.NumPins(); n != 1 {
+		t.Errorf("%d pins, want 1", n)
+	}
+}
+
+// sliceBackend is a backend of a type that isn't comparable.
+type sliceBackend struct {
+	*gpiotest.Backend
+	log []string
+}
+
+func TestSameLine(t *testing.T) {
+	be, other := gpiotest.New(), gpiotest.New()
+	sb := sliceBackend{Backend: be}
+	for _, tt := range []struct {
+		what string
+		a, b *gpio.Pin
+		want bool
+	}{
+		{"same number and backend",
+			&gpio.Pin{Gpio: 3, Name: "a", Backend: be},
+			&gpio.Pin{Gpio: 3, Name: "b", Backend: be}, true},
+		{"package backend",
+			&gpio.Pin{Gpio: 3, Name: "a"},
+			&gpio.Pin{Gpio: 3, Name: "b"}, true},
+		{"other number",
+			&gpio.Pin{Gpio: 3, Name: "a", Backend: be},
+			&gpio.Pin{Gpio: 4, Name: "b", Backend: be}, false},
+		{"other backend",
+			&gpio.Pin{Gpio: 3, Name: "a", Backend: be},
+			&gpio.Pin{Gpio: 3, Name: "b", Backend: other}, false},
+		{"package and own backend",
+			&gpio.Pin{Gpio: 3, Name: "a", Backend: be},
+			&gpio.Pin{Gpio: 3, Name: "b"}, false},
+		{"not comparable",
+			&gpio.Pin{Gpio: 3, Name: "a", Backend: sb},
+			&gpio.Pin{Gpio: 3, Name: "b", Backend: sb}, false},
+		{"nil", &gpio.Pin{Gpio: 3, Name: "a"}, nil, false},
+	} {
+		if got := tt.a.SameLine(tt.b); got != tt.want {
+			t.Errorf("%s: SameLine %v, want %v", tt.what, got, tt.want)
+		}
+	}
+}
+
+func TestReapply(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 8, Name: "reset_l", ActiveLow: true, Backend: be}
+	if err := p.SetDirection("low"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetValue(true); err != nil {
+		t.Fatal(err)
+	}
+	// Released and reclaimed by another, the line is back to an input.
+	be.Unexport(p)
+	be.Export(p)
+	be.SetDirection(&gpio.Pin{Gpio: 8, Backend: be}, "in")
+	be.ClearOps()
+	if err := p.Reapply(); err != nil {
+		t.Fatal(err)
+	}
+	if dir, _ := be.Direction(p); dir != "out" || be.Level(8) {
+		t.Errorf("reapplied %s at level %v, want out low", dir,
+			be.Level(8))
+	}
+	want := []gpiotest.Op{{Gpio: 8, Kind: "direction", Arg: "high"}}
+	if ops := be.Ops(); !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops %v, want %v", ops, want)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 9, Name: "present", Backend: be}
+	gpio.SetHistoryDepth(p, 3)
+	c, err := p.Watch(gpio.EdgeBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Watch(gpio.EdgeNone)
+	values := []bool{true, false, true, false, true}
+	for _, v := range values {
+		be.Inject(9, v)
+	}
+	for i := range values {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %d of %d events", i, len(values))
+		}
+	}
+	var got []bool
+	for _, e := range p.History() {
+		got = append(got, e.Value)
+	}
+	if want := values[2:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("history %v, want %v", got, want)
+	}
+	if n := p.Counters().Edges; n != uint64(len(values)) {
+		t.Errorf("%d edges counted, want %d", n, len(values))
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 10, Name: "fault_l", ActiveLow: true, Backend: be}
+	if err := p.SetDirection("high"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	want := gpio.Attrs{Direction: "out", Value: true, ActiveLow: true}
+	if p.Live != want || p.LastRefresh.IsZero() {
+		t.Errorf("live %+v at %v, want %+v", p.Live, p.LastRefresh, want)
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package gpiotest provides an in-memory gpio.Backend so that code driving
+// gpio pins can be tested without hardware or a fake sysfs tree.
+//
+//	b := gpiotest.New()
+//	p := &gpio.Pin{Gpio: 5, Name: "led", Backend: b}
+//	p.SetDirection("high")
+//	b.Ops() // [{5 direction high}]
+//
+// Inputs are driven by Inject, by Play of scripted waveforms, e.g. as
+// recorded in a VCD file and read by ParseVCD, or by an output wired to
+// them by Loopback.
+package gpiotest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+// Op is a recorded pin operation that changed state.
+type Op struct {
+	Gpio int
+	// "export", "unexport", "direction" or "value".
+	Kind string
+	// Direction written or, for values, "1" or "0", as given by the
+	// caller; ActiveLow pins record the logical value.
+	Arg string
+}
+
+func (op Op) String() string {
+	return fmt.Sprintf("gpio%d %s %s", op.Gpio, op.Kind, op.Arg)
+}
+
+// Events buffered by a watch; Inject drops edges beyond these.
+const WatchDepth = 64
+
+// Backend keeps the state of every line in memory. Lines spring into
+// existence as inputs at low level on first use, whether or not they're
+// exported. It's safe for concurrent use.
+type Backend struct {
+	mu    sync.Mutex
+	lines map[int]*line
+	ops   []Op
+}
+
+type line struct {
+	exported bool
+	dir      string
+	// Electrical level.
+	level bool
+	watch struct {
+		p    *gpio.Pin
+		edge gpio.Edge
+		c    chan gpio.Event
+	}
+	// Line following this one's level, if any.
+	loop *loop
+}
+
+func New() *Backend {
+	return &Backend{lines: make(map[int]*line)}
+}
+
+func (b *Backend) line(gpio int) *line {
+	l := b.lines[gpio]
+	if l == nil {
+		l = &line{dir: "in"}
+		b.lines[gpio] = l
+	}
+	return l
+}
+
+func (b *Backend) record(gpio int, kind, arg string) {
+	b.ops = append(b.ops, Op{Gpio: gpio, Kind: kind, Arg: arg})
+}
+
+// Ops returns the operations recorded since New or the last ClearOps.
+func (b *Backend) Ops() []Op {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Op(nil), b.ops...)
+}
+
+func (b *Backend) ClearOps() {
+	b.mu.Lock()
+	b.ops = nil
+	b.mu.Unlock()
+}
+
+// Level returns the electrical level of a line.
+func (b *Backend) Level(gpio int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.line(gpio).level
+}
+
+// Inject drives a line to the given electrical level from outside, as a
+// signal on an input would, and delivers the edge to a watch of it.
+func (b *Backend) Inject(gpio int, level bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.set(b.line(gpio), level, time.Now())
+}
+
+// set changes a line's level and reports the edge at time t.
+func (b *Backend) set(l *line, level bool, t time.Time) {
+	if l.level == level {
+		return
+	}
+	l.level = level
+	if l.loop != nil {
+		b.carry(l.loop, level, t)
+	}
+	p := l.watch.p
+	if p == nil {
+		return
+	}
+	v := level != p.ActiveLow
+	switch l.watch.edge {
+	case gpio.EdgeRising:
+		if !v {
+			return
+		}
+	case gpio.EdgeFalling:
+		if v {
+			return
+		}
+	}
+	e := gpio.Event{Pin: p, EdgeEvent: gpio.EdgeEvent{
+		Rising: v,
+		Value:  v,
+		Time:   t,
+	}}
+	select {
+	case l.watch.c <- e:
+	default:
+	}
+}
+
+func (b *Backend) Export(p *gpio.Pin) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.line(p.Gpio).exported = true
+	b.record(p.Gpio, "export", "")
+	return nil
+}
+
+func (b *Backend) Unexport(p *gpio.Pin) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.line(p.Gpio).exported = false
+	b.record(p.Gpio, "unexport", "")
+	return nil
+}
+
+func (b *Backend) IsExported(p *gpio.Pin) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.line(p.Gpio).exported
+}
+
+func (b *Backend) Direction(p *gpio.Pin) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.line(p.Gpio).dir, nil
+}
+
+func (b *Backend) SetDirection(p *gpio.Pin, dir string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.line(p.Gpio)
+	switch dir {
+	case "in":
+		l.dir = "in"
+	case "out", "low", "high":
+		l.dir = "out"
+		b.set(l, (dir == "high") != p.ActiveLow, time.Now())
+	default:
+		return fmt.Errorf("%s: invalid direction %q", p, dir)
+	}
+	b.record(p.Gpio, "direction", dir)
+	return nil
+}
+
+func (b *Backend) Read(p *gpio.Pin) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.line(p.Gpio).level != p.ActiveLow, nil
+}
+
+// Write sets an output's level; like the kernel, it fails on an input.
+func (b *Backend) Write(p *gpio.Pin, v bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.line(p.Gpio)
+	if l.dir != "out" {
+		return fmt.Errorf("%s: write to input", p)
+	}
+	b.set(l, v != p.ActiveLow, time.Now())
+	arg := "0"
+	if v {
+		arg = "1"
+	}
+	b.record(p.Gpio, "value", arg)
+	return nil
+}
+
+func (b *Backend) Watch(p *gpio.Pin, edge gpio.Edge) (<-chan gpio.Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.line(p.Gpio)
+	if l.watch.c != nil {
+		close(l.watch.c)
+		l.watch.p, l.watch.c = nil, nil
+	}
+	if edge == gpio.EdgeNone {
+		return nil, nil
+	}
+	l.watch.p, l.watch.edge = p, edge
+	l.watch.c = make(chan gpio.Event, WatchDepth)
+	return l.watch.c, nil
+}
@@ -0,0 +1,172 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpiotest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+func TestOps(t *testing.T) {
+	b := New()
+	p := &gpio.Pin{Gpio: 5, Name: "led", Backend: b}
+	if err := b.Export(p); err != nil {
+		t.Fatal(err)
+	}
+	if !b.IsExported(p) {
+		t.Error("not exported after Export")
+	}
+	if err := p.SetDirection("high"); err != nil {
+		t.Fatal(err)
+	}
+	if !b.Level(5) {
+		t.Error("high output at low level")
+	}
+	if err := p.SetValue(false); err != nil {
+		t.Fatal(err)
+	}
+	if dir, _ := b.Direction(p); dir != "out" {
+		t.Errorf("direction %q, want out", dir)
+	}
+	if err := b.Unexport(p); err != nil {
+		t.Fatal(err)
+	}
+	want := []Op{
+		{5, "export", ""},
+		{5, "direction", "high"},
+		{5, "value", "0"},
+		{5, "unexport", ""},
+	}
+	if ops := b.Ops(); !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops %v, want %v", ops, want)
+	}
+	b.ClearOps()
+	if ops := b.Ops(); len(ops) != 0 {
+		t.Errorf("ops %v after ClearOps", ops)
+	}
+}
+
+func TestOpsActiveLow(t *testing.T) {
+	b := New()
+	p := &gpio.Pin{Gpio: 1, Name: "reset_l", ActiveLow: true, Backend: b}
+	if err := p.SetDirection("high"); err != nil {
+		t.Fatal(err)
+	}
+	if b.Level(1) {
+		t.Error("active low high output at high level")
+	}
+	if v, _ := b.Read(p); !v {
+		t.Error("active low high output reads low")
+	}
+}
+
+func TestWriteInput(t *testing.T) {
+	b := New()
+	p := &gpio.Pin{Gpio: 2, Name: "button", Backend: b}
+	if err := b.Write(p, true); err == nil {
+		t.Error("write to input didn't fail")
+	}
+	if err := b.SetDirection(p, "sideways"); err == nil {
+		t.Error("invalid direction didn't fail")
+	}
+	if ops := b.Ops(); len(ops) != 0 {
+		t.Errorf("failed ops recorded %v", ops)
+	}
+}
+
+func TestInject(t *testing.T) {
+	b := New()
+	p := &gpio.Pin{Gpio: 3, Name: "present", Backend: b}
+	b.Inject(3, true)
+	if v, err := p.Value(); err != nil || !v {
+		t.Errorf("value %v, %v after inject high", v, err)
+	}
+	b.Inject(3, false)
+	if b.Level(3) {
+		t.Error("level high after inject low")
+	}
+}
+
+// events receives n events from c, failing on a timeout.
+func events(t *testing.T, c <-chan gpio.Event, n int) []gpio.Event {
+	t.Helper()
+	var l []gpio.Event
+	for len(l) < n {
+		select {
+		case e, ok := <-c:
+			if !ok {
+				t.Fatalf("watch closed after %d of %d events", len(l), n)
+			}
+			l = append(l, e)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %d of %d events", len(l), n)
+		}
+	}
+	return l
+}
+
+func TestWatch(t *testing.T) {
+	for _, tt := range []struct {
+		edge gpio.Edge
+		want []bool
+	}{
+		{gpio.EdgeBoth, []bool{true, false, true, false}},
+		{gpio.EdgeRising, []bool{true, true}},
+		{gpio.EdgeFalling, []bool{false, false}},
+	} {
+		b := New()
+		p := &gpio.Pin{Gpio: 4, Name: "alert", Backend: b}
+		c, err := b.Watch(p, tt.edge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []bool{true, true, false, true, false} {
+			b.Inject(4, v)
+		}
+		var got []bool
+		for _, e := range events(t, c, len(tt.want)) {
+			if e.Pin != p || e.Rising != e.Value {
+				t.Errorf("%v: bad event %+v", tt.edge, e)
+			}
+			got = append(got, e.Value)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: values %v, want %v", tt.edge, got, tt.want)
+		}
+		select {
+		case e := <-c:
+			t.Errorf("%v: extra event %+v", tt.edge, e)
+		default:
+		}
+	}
+}
+
+func TestWatchReplace(t *testing.T) {
+	b := New()
+	p := &gpio.Pin{Gpio: 6, Name: "alert", Backend: b}
+	c, _ := b.Watch(p, gpio.EdgeBoth)
+	if _, err := b.Watch(p, gpio.EdgeNone); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-c; ok {
+		t.Error("replaced watch not closed")
+	}
+	b.Inject(6, true)
+}
+
+func TestWatchDepth(t *testing.T) {
+	b := New()
+	p := &gpio.Pin{Gpio: 7, Name: "noisy", Backend: b}
+	c, _ := b.Watch(p, gpio.EdgeBoth)
+	for i := 0; i < 2*WatchDepth; i++ {
+		b.Inject(7, i%2 == 0)
+	}
+	if n := len(c); n != WatchDepth {
+		t.Errorf("%d events buffered, want %d", n, WatchDepth)
+	}
+}
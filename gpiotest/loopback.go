@@ -0,0 +1,79 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpiotest
+
+import (
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+// loop carries the level changes of a line to another.
+type loop struct {
+	to    *line
+	delay time.Duration
+	// Changes waiting out the delay, in order.
+	pending []Sample
+	start   time.Time
+}
+
+// Loopback wires line out to line in, whose level then follows out's after
+// delay, as though through a cable or a buffer, e.g. to test a watcher or a
+// sequencer end to end. The in line takes out's level now; a delay of 0
+// changes it together with out. Loopback of out with a negative delay
+// unwires it.
+func (b *Backend) Loopback(out, in int, delay time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.line(out)
+	if delay < 0 {
+		l.loop = nil
+		return
+	}
+	l.loop = &loop{to: b.line(in), delay: delay, start: time.Now()}
+	b.set(l.loop.to, l.level, time.Now())
+}
+
+// Pair returns an output, driven low, and an input on new lines of the
+// backend wired by Loopback.
+func (b *Backend) Pair(outName, inName string, delay time.Duration) (out, in *gpio.Pin) {
+	b.mu.Lock()
+	n := 0
+	for g := range b.lines {
+		if g >= n {
+			n = g + 1
+		}
+	}
+	b.line(n)
+	b.line(n + 1)
+	b.mu.Unlock()
+	out = &gpio.Pin{Gpio: n, Name: outName, Backend: b}
+	in = &gpio.Pin{Gpio: n + 1, Name: inName, Backend: b}
+	out.SetDirection("low")
+	b.Loopback(n, n+1, delay)
+	return
+}
+
+// carry passes on a change of the looped line at time t; called with mu
+// held.
+func (b *Backend) carry(lp *loop, level bool, t time.Time) {
+	if lp.delay == 0 {
+		b.set(lp.to, level, t)
+		return
+	}
+	lp.pending = append(lp.pending, Sample{t.Add(lp.delay).Sub(lp.start),
+		level})
+	time.AfterFunc(lp.delay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// Timers may run out of order; apply every change that's due.
+		now := time.Since(lp.start)
+		for len(lp.pending) > 0 && lp.pending[0].At <= now {
+			s := lp.pending[0]
+			lp.pending = lp.pending[1:]
+			b.set(lp.to, s.Level, lp.start.Add(s.At))
+		}
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpiotest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+func TestLoopback(t *testing.T) {
+	b := New()
+	out, in := b.Pair("out", "in", 0)
+	c, err := b.Watch(in, gpio.EdgeBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []bool{true, false} {
+		if err := out.SetValue(v); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := in.Value(); got != v {
+			t.Errorf("in %v after out %v", got, v)
+		}
+	}
+	l := events(t, c, 2)
+	if !l[0].Value || l[1].Value {
+		t.Errorf("events %+v", l)
+	}
+	b.Loopback(out.Gpio, in.Gpio, -1)
+	out.SetValue(true)
+	if v, _ := in.Value(); v {
+		t.Error("in follows out after unwiring")
+	}
+}
+
+func TestLoopbackDelay(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	b := New()
+	out, in := b.Pair("out", "in", delay)
+	c, _ := b.Watch(in, gpio.EdgeBoth)
+	start := time.Now()
+	out.SetValue(true)
+	out.SetValue(false)
+	out.SetValue(true)
+	if v, _ := in.Value(); v {
+		t.Error("in high before the delay")
+	}
+	l := events(t, c, 3)
+	for i, e := range l {
+		if e.Value != (i%2 == 0) {
+			t.Errorf("event %d value %v", i, e.Value)
+		}
+		if d := e.Time.Sub(start); d < delay {
+			t.Errorf("event %d after %v, want at least %v", i, d,
+				delay)
+		}
+	}
+	if !l[0].Time.Before(l[1].Time) || !l[1].Time.Before(l[2].Time) {
+		t.Errorf("events out of order %+v", l)
+	}
+	if v, _ := in.Value(); !v {
+		t.Error("in low after the delay")
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpiotest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample is a line's electrical level from a time into a waveform on.
+type Sample struct {
+	At    time.Duration
+	Level bool
+}
+
+// Waveform is a line's levels in time order, e.g. a bouncing button
+//
+//	gpiotest.Waveform{{0, false}, {10 * time.Millisecond, true},
+//		{11 * time.Millisecond, false}, {12 * time.Millisecond, true}}
+type Waveform []Sample
+
+// Play injects the waveforms of the lines, by gpio, in real time, as
+// Inject, returning at the end of the longest or when ctx is done. The
+// events are timed by the waveforms from the start rather than the clock,
+// so that their times are repeatable however late the sleeps wake.
+func (b *Backend) Play(ctx context.Context, waves map[int]Waveform) error {
+	type step struct {
+		gpio int
+		Sample
+	}
+	var steps []step
+	for gpio, w := range waves {
+		for _, s := range w {
+			steps = append(steps, step{gpio, s})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].At < steps[j].At
+	})
+	start := time.Now()
+	t := time.NewTimer(0)
+	defer t.Stop()
+	<-t.C
+	for _, s := range steps {
+		if d := time.Until(start.Add(s.At)); d > 0 {
+			t.Reset(d)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		b.mu.Lock()
+		b.set(b.line(s.gpio), s.Level, start.Add(s.At))
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// ParseVCD reads the single bit signals of a Value Change Dump, e.g. of a
+// gpio.Recorder or a logic analyzer, as waveforms by signal name, timed
+// from the dump's time 0. Levels x and z read as low.
+func ParseVCD(r io.Reader) (map[string]Waveform, error) {
+	s := bufio.NewScanner(r)
+	s.Split(bufio.ScanWords)
+	next := func() (string, bool) {
+		if !s.Scan() {
+			return "", false
+		}
+		return s.Text(), true
+	}
+	// The tokens of a declaration up to its $end.
+	decl := func() (l []string, err error) {
+		for {
+			tok, ok := next()
+			if !ok {
+				return nil, fmt.Errorf("vcd: unterminated declaration")
+			}
+			if tok == "$end" {
+				return
+			}
+			l = append(l, tok)
+		}
+	}
+	unit := time.Nanosecond
+	names := make(map[string][]string)
+	waves := make(map[string]Waveform)
+	var at time.Duration
+	for {
+		tok, ok := next()
+		if !ok {
+			break
+		}
+		switch {
+		case tok == "$timescale":
+			l, err := decl()
+			if err != nil {
+				return nil, err
+			}
+			if unit, err = vcdTimescale(strings.Join(l, "")); err != nil {
+				return nil, err
+			}
+		case tok == "$var":
+			l, err := decl()
+			if err != nil {
+				return nil, err
+			}
+			if len(l) < 4 {
+				return nil, fmt.Errorf("vcd: bad $var %q", l)
+			}
+			if l[1] == "1" {
+				names[l[2]] = append(names[l[2]], l[3])
+			}
+		case tok == "$dumpvars", tok == "$dumpon", tok == "$dumpoff",
+			tok == "$dumpall", tok == "$end":
+			// Changes follow as usual.
+		case tok[0] == '$':
+			if _, err := decl(); err != nil {
+				return nil, err
+			}
+		case tok[0] == '#':
+			n, err := strconv.ParseInt(tok[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("vcd: bad time %q", tok)
+			}
+			at = time.Duration(n) * unit
+		case tok[0] == 'b', tok[0] == 'B', tok[0] == 'r', tok[0] == 'R':
+			// Vectors and reals; skip their identifier.
+			next()
+		default:
+			level := tok[0] == '1'
+			for _, name := range names[tok[1:]] {
+				w := waves[name]
+				if n := len(w); n > 0 && w[n-1].Level == level {
+					continue
+				}
+				waves[name] = append(w, Sample{at, level})
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return waves, nil
+}
+
+// vcdTimescale parses a timescale, e.g. 10us.
+func vcdTimescale(s string) (time.Duration, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i <= 0 {
+		return 0, fmt.Errorf("vcd: bad timescale %q", s)
+	}
+	n, _ := strconv.Atoi(s[:i])
+	units := map[string]time.Duration{"s": time.Second,
+		"ms": time.Millisecond, "us": time.Microsecond,
+		"ns": time.Nanosecond}
+	u, f := units[s[i:]]
+	if !f || n == 0 {
+		return 0, fmt.Errorf("vcd: unsupported timescale %q", s)
+	}
+	return time.Duration(n) * u, nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpiotest
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+func TestParseVCD(t *testing.T) {
+	f, err := os.Open("testdata/button.vcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	waves, err := ParseVCD(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms := time.Millisecond
+	want := map[string]Waveform{
+		"button": {{0, false}, {10 * ms, true},
+			{10*ms + 50*time.Microsecond, false},
+			{10*ms + 100*time.Microsecond, true}, {30 * ms, false}},
+		"led":    {{0, false}, {12 * ms, true}, {30 * ms, false}},
+		"unused": {{0, false}},
+	}
+	if !reflect.DeepEqual(waves, want) {
+		t.Errorf("waves\n%v\nwant\n%v", waves, want)
+	}
+}
+
+func TestParseVCDRecorded(t *testing.T) {
+	start := time.Now()
+	var buf bytes.Buffer
+	err := gpio.WriteVCD(&buf, []gpio.EdgeEvent{
+		{Value: true, Time: start},
+		{Value: false, Time: start.Add(3 * time.Millisecond)},
+		{Value: true, Time: start.Add(5 * time.Millisecond)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waves, err := ParseVCD(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Waveform{{0, true}, {3 * time.Millisecond, false},
+		{5 * time.Millisecond, true}}
+	if !reflect.DeepEqual(waves["pin"], want) {
+		t.Errorf("wave %v, want %v", waves["pin"], want)
+	}
+}
+
+func TestParseVCDErrors(t *testing.T) {
+	for _, s := range []string{
+		"$timescale 1 fs $end",
+		"$timescale us $end",
+		"$var wire 1 ! $end",
+		"$comment unterminated",
+		"#ten",
+	} {
+		if _, err := ParseVCD(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestPlay(t *testing.T) {
+	b := New()
+	p := &gpio.Pin{Gpio: 1, Name: "button", Backend: b}
+	c, _ := b.Watch(p, gpio.EdgeBoth)
+	ms := time.Millisecond
+	waves := map[int]Waveform{
+		1: {{0, false}, {20 * ms, true}, {21 * ms, false},
+			{22 * ms, true}, {60 * ms, false}},
+	}
+	start := time.Now()
+	if err := b.Play(context.Background(), waves); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 60*ms {
+		t.Errorf("played in %v, want at least 60ms", d)
+	}
+	l := events(t, c, 4)
+	// The first event is timed by the clock at the start, within the
+	// sleep's lateness; the rest by the waveform from it.
+	if d := l[0].Time.Sub(start); d < 20*ms || d > 20*ms+50*ms {
+		t.Errorf("first edge at %v, want about 20ms", d)
+	}
+	for i, d := range []time.Duration{ms, 2 * ms, 40 * ms} {
+		if got := l[i+1].Time.Sub(l[0].Time); got != d {
+			t.Errorf("edge %d at %v after the first, want %v", i+1,
+				got, d)
+		}
+		if l[i+1].Value != (i%2 == 1) {
+			t.Errorf("edge %d value %v", i+1, l[i+1].Value)
+		}
+	}
+}
+
+func TestPlayCancel(t *testing.T) {
+	b := New()
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	err := b.Play(ctx, map[int]Waveform{1: {{time.Hour, true}}})
+	if err != context.DeadlineExceeded {
+		t.Errorf("err %v, want %v", err, context.DeadlineExceeded)
+	}
+	if b.Level(1) {
+		t.Error("cancelled sample played")
+	}
+}
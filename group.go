@@ -0,0 +1,187 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// PinGroup reads and writes up to 64 pins in one operation; bit i of its
+// values is Pins[i].
+//
+// With the character device the pins of each chip are one line request,
+// so they change together. The group takes over the pins' lines, stopping
+// any watch, and the pins can't be used on their own until it's closed.
+// With sysfs the value attributes are held open and written back to back.
+// Other backends go pin by pin.
+type PinGroup struct {
+	Pins []*Pin
+
+	mu sync.Mutex
+	g  lineGroup
+}
+
+// lineGroup is a PinGroup's open lines.
+type lineGroup interface {
+	read() (uint64, error)
+	write(bits, mask uint64) error
+	setDirection(dir string) error
+	close() error
+}
+
+// grouper is implemented by backends that can hold a group of pins.
+type grouper interface {
+	openGroup(pins []*Pin) (lineGroup, error)
+}
+
+func NewPinGroup(pins ...*Pin) (*PinGroup, error) {
+	if len(pins) == 0 || len(pins) > 64 {
+		return nil, fmt.Errorf("pin group of %d pins", len(pins))
+	}
+	b := pins[0].backend()
+	seen := make(map[int]bool)
+	for _, p := range pins {
+		if seen[p.Gpio] {
+			return nil, fmt.Errorf("%s: twice in pin group", p)
+		}
+		seen[p.Gpio] = true
+		if p.backend() != b {
+			b = nil
+		}
+	}
+	g := &PinGroup{Pins: append([]*Pin(nil), pins...)}
+	if x, f := b.(grouper); f {
+		lg, err := x.openGroup(g.Pins)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", g, err)
+		}
+		g.g = lg
+	} else {
+		g.g = pinByPin(g.Pins)
+	}
+	return g, nil
+}
+
+func (g *PinGroup) String() string {
+	names := make([]string, len(g.Pins))
+	for i, p := range g.Pins {
+		names[i] = p.String()
+	}
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+func (g *PinGroup) mask() uint64 {
+	return ^uint64(0) >> uint(64-len(g.Pins))
+}
+
+func (g *PinGroup) Read() (bits uint64, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.g == nil {
+		return 0, fmt.Errorf("%s: closed", g)
+	}
+	if bits, err = g.g.read(); err != nil {
+		err = fmt.Errorf("%s: %v", g, err)
+	}
+	return
+}
+
+// Write sets every pin of the group.
+func (g *PinGroup) Write(bits uint64) error {
+	return g.WriteMasked(bits, g.mask())
+}
+
+// WriteMasked sets the pins of the set mask bits, leaving the others.
+func (g *PinGroup) WriteMasked(bits, mask uint64) (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.g == nil {
+		return fmt.Errorf("%s: closed", g)
+	}
+	if err = g.g.write(bits&mask, mask&g.mask()); err != nil {
+		return fmt.Errorf("%s: %v", g, err)
+	}
+	// Pin by pin, the writes were audited by SetValue.
+	if _, f := g.g.(pinByPin); !f {
+		for i, p := range g.Pins {
+			if bit := uint64(1) << uint(i); mask&bit != 0 {
+				p.audit("group write", bits&bit != 0)
+			}
+		}
+	}
+	return
+}
+
+// SetDirection sets every pin of the group to the given direction, as
+// Pin.SetDirection. Pins are left in their present direction until then.
+func (g *PinGroup) SetDirection(dir string) (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.g == nil {
+		return fmt.Errorf("%s: closed", g)
+	}
+	if err = g.g.setDirection(dir); err != nil {
+		return fmt.Errorf("%s: %v", g, err)
+	}
+	for _, p := range g.Pins {
+		p.noteDirection(dir)
+		if dir == "high" || dir == "low" {
+			p.audit("set direction", dir == "high")
+		}
+	}
+	return
+}
+
+// Close releases the group's lines.
+func (g *PinGroup) Close() (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.g != nil {
+		err = g.g.close()
+		g.g = nil
+	}
+	return
+}
+
+// pinByPin is the group of a backend without grouper.
+type pinByPin []*Pin
+
+func (l pinByPin) read() (bits uint64, err error) {
+	for i, p := range l {
+		v, err := p.Value()
+		if err != nil {
+			return 0, err
+		}
+		if v {
+			bits |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+func (l pinByPin) write(bits, mask uint64) error {
+	for i, p := range l {
+		if mask&(1<<uint(i)) == 0 {
+			continue
+		}
+		if err := p.SetValue(bits&(1<<uint(i)) != 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l pinByPin) setDirection(dir string) error {
+	for _, p := range l {
+		if err := p.backend().SetDirection(p, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pinByPin) close() error { return nil }
@@ -0,0 +1,52 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+// edgeRing keeps the last of a pin's edges.
+type edgeRing struct {
+	l       []EdgeEvent
+	next    int
+	wrapped bool
+}
+
+func (r *edgeRing) add(e EdgeEvent) {
+	if len(r.l) == 0 {
+		return
+	}
+	r.l[r.next] = e
+	if r.next++; r.next == len(r.l) {
+		r.next, r.wrapped = 0, true
+	}
+}
+
+func (r *edgeRing) events() []EdgeEvent {
+	l := make([]EdgeEvent, 0, len(r.l))
+	if r.wrapped {
+		l = append(l, r.l[r.next:]...)
+	}
+	return append(l, r.l[:r.next]...)
+}
+
+// SetHistoryDepth has the pin keep its last n edges delivered to watches,
+// as counted by Counters, for History; that discards any kept before and
+// 0, the default, keeps none. The ring of n edges is allocated at once,
+// at 32 bytes an edge on 64-bit machines, e.g. 32KiB for 1024 edges.
+func SetHistoryDepth(p *Pin, n int) {
+	mu.Lock()
+	defer mu.Unlock()
+	p.hist = edgeRing{}
+	if n > 0 {
+		p.hist.l = make([]EdgeEvent, n)
+	}
+}
+
+// History returns a copy of the edges kept by SetHistoryDepth, oldest
+// first, e.g. to look into a presence pin that bounced overnight. It's safe
+// to call while the pin is watched.
+func (p *Pin) History() []EdgeEvent {
+	mu.Lock()
+	defer mu.Unlock()
+	return p.hist.events()
+}
@@ -0,0 +1,190 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NewHTTPHandler returns a handler of the pin registry serving
+//
+//	GET /pins		every pin, as PinMap.MarshalJSON
+//	GET /pins/{name}	the pin, as Pin.MarshalJSON
+//	PUT /pins/{name}/value	set the output to the body, 0, 1, true or false
+//	GET /events		a text/event-stream of edges, as Event.MarshalJSON
+//
+// The events are of the pins named by the pin query parameters, or else of
+// every input, and of the edge parameter's edges, both by default, e.g.
+//
+//	curl -N 'localhost:8080/events?pin=button0&edge=falling'
+//
+// Mount it elsewhere with http.StripPrefix.
+func NewHTTPHandler() http.Handler { return httpHandler{} }
+
+type httpHandler struct{}
+
+func (httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/pins":
+		if httpMethod(w, r, http.MethodGet) {
+			pm := make(PinMap)
+			for _, p := range pinList() {
+				pm[p.Name] = p
+			}
+			httpJSON(w, pm.infos())
+		}
+	case path == "/events":
+		if httpMethod(w, r, http.MethodGet) {
+			httpEvents(w, r)
+		}
+	case strings.HasPrefix(path, "/pins/"):
+		name := strings.TrimPrefix(path, "/pins/")
+		if strings.HasSuffix(name, "/value") {
+			name = strings.TrimSuffix(name, "/value")
+			if httpMethod(w, r, http.MethodPut) {
+				httpSetValue(w, r, name)
+			}
+			return
+		}
+		if !httpMethod(w, r, http.MethodGet) {
+			return
+		}
+		p, err := lookupPin(name)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		httpJSON(w, p.info(Chips()))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// httpMethod reports whether the request is of the method, otherwise
+// replying that it's not allowed.
+func httpMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method ||
+		(method == http.MethodGet && r.Method == http.MethodHead) {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func httpJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	e.Encode(v)
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNoSuchPin):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrBusy):
+		code = http.StatusConflict
+	}
+	http.Error(w, err.Error(), code)
+}
+
+func httpSetValue(w http.ResponseWriter, r *http.Request, name string) {
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 64))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v, err := strconv.ParseBool(strings.TrimSpace(string(b)))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("bad value %q", b),
+			http.StatusBadRequest)
+		return
+	}
+	if err = SetValue(name, v); err != nil {
+		httpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func httpEvents(w http.ResponseWriter, r *http.Request) {
+	fl, f := w.(http.Flusher)
+	if !f {
+		http.Error(w, "streaming unsupported",
+			http.StatusInternalServerError)
+		return
+	}
+	q := r.URL.Query()
+	edge := EdgeBoth
+	if s := q.Get("edge"); s != "" {
+		edge = -1
+		for e, n := range edgeNames {
+			if n == s && Edge(e) != EdgeNone {
+				edge = Edge(e)
+			}
+		}
+		if edge < 0 {
+			http.Error(w, fmt.Sprintf("bad edge %q", s),
+				http.StatusBadRequest)
+			return
+		}
+	}
+	var l []*Pin
+	for _, name := range q["pin"] {
+		p, err := lookupPin(name)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		l = append(l, p)
+	}
+	if len(l) == 0 {
+		for _, p := range pinList() {
+			if dir, err := p.Direction(); err == nil && dir == "in" {
+				l = append(l, p)
+			}
+		}
+	}
+	m, err := NewEventMonitor()
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	defer m.Close()
+	for _, p := range l {
+		if err = m.Add(p, edge); err != nil {
+			httpError(w, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fl.Flush()
+	for {
+		select {
+		case e, ok := <-m.Events():
+			if !ok {
+				return
+			}
+			b, _ := json.Marshal(e)
+			if _, err = fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+			fl.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
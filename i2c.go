@@ -0,0 +1,231 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"time"
+)
+
+// I2CMaster bit-bangs an I2C bus with 7-bit addressing.
+//
+// The pins are run open-drain by switching them between driven low and
+// input, so both lines need external pull-ups; the levels are the lines',
+// whether or not the pins are ActiveLow. Each edge costs a sysfs
+// write and the bus runs at a few kHz at best, well under the 100kHz
+// standard mode; it suits the occasional register access on pins that
+// don't reach an I2C controller.
+type I2CMaster struct {
+	SDA, SCL *Pin
+	// Minimum time between clock edges.
+	HalfPeriod time.Duration
+	// Longest a slave may stretch the clock by holding SCL low.
+	StretchTimeout time.Duration
+}
+
+// NewI2CMaster releases both lines and checks that they're pulled high.
+func NewI2CMaster(sda, scl *Pin) (*I2CMaster, error) {
+	m := &I2CMaster{SDA: sda, SCL: scl, StretchTimeout: 25 * time.Millisecond}
+	for _, p := range []*Pin{sda, scl} {
+		if err := m.release(p); err != nil {
+			return nil, err
+		}
+		if v, err := p.lineLevel(); err != nil {
+			return nil, err
+		} else if !v {
+			return nil, fmt.Errorf("%s: held low; missing pull-up?", p)
+		}
+	}
+	return m, nil
+}
+
+// Write sends data to the device at addr.
+func (m *I2CMaster) Write(addr byte, data []byte) error {
+	return m.Tx(addr, data, nil)
+}
+
+// Read receives n bytes from the device at addr.
+func (m *I2CMaster) Read(addr byte, n int) (data []byte, err error) {
+	data = make([]byte, n)
+	if err = m.Tx(addr, nil, data); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Tx sends w to the device at addr then, after a repeated start, fills r
+// from it, e.g. to write a register number and read the register. Either
+// may be empty; with both empty the device is just addressed.
+func (m *I2CMaster) Tx(addr byte, w, r []byte) (err error) {
+	if err = m.start(); err != nil {
+		return
+	}
+	defer m.stop(&err)
+	if len(w) != 0 || len(r) == 0 {
+		if err = m.address(addr, false); err != nil {
+			return
+		}
+		for i, b := range w {
+			ack, e := m.writeByte(b)
+			if e != nil {
+				return e
+			}
+			if !ack {
+				return fmt.Errorf("i2c %#x: nack at byte %d", addr, i)
+			}
+		}
+		if len(r) == 0 {
+			return
+		}
+		if err = m.start(); err != nil {
+			return
+		}
+	}
+	if err = m.address(addr, true); err != nil {
+		return
+	}
+	for i := range r {
+		if r[i], err = m.readByte(i < len(r)-1); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (m *I2CMaster) address(addr byte, read bool) error {
+	if addr > 0x7f {
+		return fmt.Errorf("invalid i2c address %#x", addr)
+	}
+	b := addr << 1
+	if read {
+		b |= 1
+	}
+	ack, err := m.writeByte(b)
+	if err == nil && !ack {
+		err = fmt.Errorf("i2c %#x: no device", addr)
+	}
+	return err
+}
+
+func (m *I2CMaster) release(p *Pin) error { return p.SetDirection("in") }
+func (m *I2CMaster) low(p *Pin) error     { return p.lineLow() }
+
+// Least time between the checks of a stretched clock.
+const stretchPoll = 10 * time.Microsecond
+
+// sclHigh releases SCL and waits out any clock stretching, checking the
+// line every half period, failing after StretchTimeout.
+func (m *I2CMaster) sclHigh() error {
+	if err := m.release(m.SCL); err != nil {
+		return err
+	}
+	poll := m.HalfPeriod
+	if poll < stretchPoll {
+		poll = stretchPoll
+	}
+	deadline := time.Now().Add(m.StretchTimeout)
+	for {
+		v, err := m.SCL.lineLevel()
+		if err != nil || v {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%s: clock stretched beyond %v",
+				m.SCL, m.StretchTimeout)
+		}
+		time.Sleep(poll)
+	}
+}
+
+func (m *I2CMaster) start() error {
+	if err := m.release(m.SDA); err != nil {
+		return err
+	}
+	if err := m.sclHigh(); err != nil {
+		return err
+	}
+	time.Sleep(m.HalfPeriod)
+	if err := m.low(m.SDA); err != nil {
+		return err
+	}
+	time.Sleep(m.HalfPeriod)
+	return m.low(m.SCL)
+}
+
+// stop ends the transaction, keeping the first error.
+func (m *I2CMaster) stop(err *error) {
+	e := m.low(m.SDA)
+	if e == nil {
+		e = m.sclHigh()
+	}
+	time.Sleep(m.HalfPeriod)
+	if e == nil {
+		e = m.release(m.SDA)
+	}
+	time.Sleep(m.HalfPeriod)
+	if *err == nil {
+		*err = e
+	}
+}
+
+func (m *I2CMaster) writeBit(b bool) error {
+	var err error
+	if b {
+		err = m.release(m.SDA)
+	} else {
+		err = m.low(m.SDA)
+	}
+	if err != nil {
+		return err
+	}
+	time.Sleep(m.HalfPeriod)
+	if err = m.sclHigh(); err != nil {
+		return err
+	}
+	time.Sleep(m.HalfPeriod)
+	return m.low(m.SCL)
+}
+
+func (m *I2CMaster) readBit() (b bool, err error) {
+	if err = m.release(m.SDA); err != nil {
+		return
+	}
+	time.Sleep(m.HalfPeriod)
+	if err = m.sclHigh(); err != nil {
+		return
+	}
+	if b, err = m.SDA.lineLevel(); err != nil {
+		return
+	}
+	time.Sleep(m.HalfPeriod)
+	err = m.low(m.SCL)
+	return
+}
+
+// writeByte shifts out b and returns whether the slave acknowledged it.
+func (m *I2CMaster) writeByte(b byte) (ack bool, err error) {
+	for i := 7; i >= 0; i-- {
+		if err = m.writeBit(b&(1<<uint(i)) != 0); err != nil {
+			return
+		}
+	}
+	nack, err := m.readBit()
+	return !nack, err
+}
+
+// readByte shifts in a byte and acknowledges it if more are wanted.
+func (m *I2CMaster) readByte(ack bool) (b byte, err error) {
+	for i := 7; i >= 0; i-- {
+		bit, e := m.readBit()
+		if e != nil {
+			return 0, e
+		}
+		if bit {
+			b |= 1 << uint(i)
+		}
+	}
+	err = m.writeBit(!ack)
+	return
+}
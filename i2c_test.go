@@ -0,0 +1,65 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+// i2cPins returns the ActiveLow SDA and SCL of a gpiotest bus pulled up.
+func i2cPins(be *gpiotest.Backend) (sda, scl *gpio.Pin) {
+	sda = &gpio.Pin{Gpio: 1, Name: "sda", ActiveLow: true, Backend: be}
+	scl = &gpio.Pin{Gpio: 2, Name: "scl", ActiveLow: true, Backend: be}
+	be.Inject(1, true)
+	be.Inject(2, true)
+	return
+}
+
+func TestI2CActiveLow(t *testing.T) {
+	be := gpiotest.New()
+	m, err := gpio.NewI2CMaster(i2cPins(be))
+	if err != nil {
+		t.Fatal(err)
+	}
+	be.ClearOps()
+	m.Write(0x50, []byte{0})
+	var low int
+	for _, op := range be.Ops() {
+		switch op.Arg {
+		case "high":
+			low++
+		case "low", "out":
+			t.Fatalf("ActiveLow line released by %v", op)
+		}
+	}
+	if low == 0 {
+		t.Error("lines never driven low")
+	}
+}
+
+func TestI2CStretchTimeout(t *testing.T) {
+	be := gpiotest.New()
+	m, err := gpio.NewI2CMaster(i2cPins(be))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.StretchTimeout = 5 * time.Millisecond
+	// A slave holds the clock low.
+	be.Inject(2, false)
+	start := time.Now()
+	err = m.Write(0x50, []byte{0})
+	if err == nil || !strings.Contains(err.Error(), "stretched") {
+		t.Fatalf("err %v, want clock stretched", err)
+	}
+	if d := time.Since(start); d < m.StretchTimeout {
+		t.Errorf("gave up after %v, want at least %v", d,
+			m.StretchTimeout)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package i2cbb is an I2C master bit-banged over two gpio pins, for
+// recovery paths and the like on pins not wired to an I2C controller.
+//
+//	m, err := i2cbb.NewByName("i2c_sda", "i2c_scl")
+//	reg := make([]byte, 1)
+//	err = m.Tx(0x50, []byte{0x00}, reg)
+//
+// It's a thin, locked wrapper of gpio.I2CMaster; see that for its limits.
+package i2cbb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+// Bus is the transaction interface shared with the common Go I2C
+// libraries: a write of w then, after a repeated start, a read of r, with
+// 7-bit addressing.
+type Bus interface {
+	Tx(addr uint16, w, r []byte) error
+	String() string
+}
+
+// Master is a Bus on an SDA and an SCL pin, both with external pull-ups.
+// It's safe for concurrent use; transactions take turns.
+type Master struct {
+	mu sync.Mutex
+	m  *gpio.I2CMaster
+}
+
+var _ Bus = (*Master)(nil)
+
+// New releases both lines and checks that they're pulled high.
+func New(sda, scl *gpio.Pin) (*Master, error) {
+	m, err := gpio.NewI2CMaster(sda, scl)
+	if err != nil {
+		return nil, err
+	}
+	return &Master{m: m}, nil
+}
+
+// NewByName is New of the pins gpio.FindPin finds by name.
+func NewByName(sda, scl string) (*Master, error) {
+	var pins [2]*gpio.Pin
+	for i, name := range []string{sda, scl} {
+		p, f := gpio.FindPin(name)
+		if !f {
+			return nil, fmt.Errorf("%s: %w", name, gpio.ErrNoSuchPin)
+		}
+		pins[i] = p
+	}
+	return New(pins[0], pins[1])
+}
+
+func (m *Master) String() string {
+	return fmt.Sprintf("i2cbb(%s,%s)", m.m.SDA, m.m.SCL)
+}
+
+// Tx runs a transaction with the device at addr.
+func (m *Master) Tx(addr uint16, w, r []byte) error {
+	if addr > 0x7f {
+		return fmt.Errorf("%s: invalid 7-bit address %#x", m, addr)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.m.Tx(byte(addr), w, r)
+}
+
+// SetSpeed sets the clock rate to at most hz; the pins' latency may well
+// keep it slower. Zero runs as fast as the pins go.
+func (m *Master) SetSpeed(hz int64) error {
+	if hz < 0 {
+		return fmt.Errorf("%s: invalid speed %d", m, hz)
+	}
+	var half time.Duration
+	if hz > 0 {
+		half = time.Second / time.Duration(2*hz)
+	}
+	m.mu.Lock()
+	m.m.HalfPeriod = half
+	m.mu.Unlock()
+	return nil
+}
+
+// SetStretchTimeout sets the longest a device may stretch the clock.
+func (m *Master) SetStretchTimeout(d time.Duration) {
+	m.mu.Lock()
+	m.m.StretchTimeout = d
+	m.mu.Unlock()
+}
@@ -0,0 +1,83 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// I2C_RDWR, from linux/i2c-dev.h.
+const (
+	i2cRdwrIoctl = 0x0707
+	i2cMsgRead   = 1
+)
+
+type i2cMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   uintptr
+}
+
+type i2cRdwrData struct {
+	msgs  uintptr
+	nmsgs uint32
+}
+
+// I2CDev is a kernel I2C adapter through /dev/i2c-N, an I2CBus.
+type I2CDev struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenI2CDev opens /dev/i2c-bus.
+func OpenI2CDev(bus int) (*I2CDev, error) {
+	f, err := os.OpenFile(fmt.Sprintf(prefix+"/dev/i2c-%d", bus),
+		os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &I2CDev{f: f}, nil
+}
+
+func (d *I2CDev) String() string { return d.f.Name() }
+
+// Tx writes w to the device at addr then, with a repeated start, reads r.
+func (d *I2CDev) Tx(addr uint16, w, r []byte) error {
+	var msgs [2]i2cMsg
+	n := 0
+	if len(w) != 0 {
+		msgs[n] = i2cMsg{addr: addr, len: uint16(len(w)),
+			buf: uintptr(unsafe.Pointer(&w[0]))}
+		n++
+	}
+	if len(r) != 0 {
+		msgs[n] = i2cMsg{addr: addr, flags: i2cMsgRead,
+			len: uint16(len(r)), buf: uintptr(unsafe.Pointer(&r[0]))}
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	data := i2cRdwrData{msgs: uintptr(unsafe.Pointer(&msgs[0])),
+		nmsgs: uint32(n)}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := ioctl(d.f, i2cRdwrIoctl, unsafe.Pointer(&data))
+	// The messages and buffers are only referenced by uintptrs.
+	runtime.KeepAlive(&msgs)
+	runtime.KeepAlive(w)
+	runtime.KeepAlive(r)
+	if err != nil {
+		return fmt.Errorf("%s: %#x: %v", d, addr, err)
+	}
+	return nil
+}
+
+func (d *I2CDev) Close() error { return d.f.Close() }
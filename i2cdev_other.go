@@ -0,0 +1,21 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import "errors"
+
+// I2CDev is a kernel I2C adapter, which needs linux.
+type I2CDev struct{}
+
+func OpenI2CDev(bus int) (*I2CDev, error) {
+	return nil, errors.New("i2c-dev requires linux")
+}
+
+func (*I2CDev) String() string                    { return "i2c-dev" }
+func (*I2CDev) Tx(addr uint16, w, r []byte) error { return errors.New("i2c-dev requires linux") }
+func (*I2CDev) Close() error                      { return nil }
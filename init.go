@@ -0,0 +1,122 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/platinasystems/fdt"
+)
+
+// Option configures Init.
+type Option func(*initConfig)
+
+type initConfig struct {
+	fdtPath   string
+	tree      *fdt.Tree
+	backend   Backend
+	prefix    string
+	setPrefix bool
+	offline   bool
+}
+
+// WithFDT has Init read the flattened device tree blob at path instead of
+// the kernel's /proc/device-tree.
+func WithFDT(path string) Option {
+	return func(c *initConfig) { c.fdtPath = path }
+}
+
+// WithBackend has Init select the package backend, as SetBackend.
+func WithBackend(b Backend) Option {
+	return func(c *initConfig) { c.backend = b }
+}
+
+// WithPrefix has Init set the file prefix, as SetDebugPrefix.
+func WithPrefix(prefix string) Option {
+	return func(c *initConfig) { c.prefix, c.setPrefix = prefix, true }
+}
+
+// WithOffline has Init build the pin map of a device tree that isn't this
+// system's, e.g. another board's: pins aren't exported or configured and
+// the bank bases are those of GpioBankToBase.
+func WithOffline() Option {
+	return func(c *initConfig) { c.offline = true }
+}
+
+// Init builds the pin map from the device tree, replacing any built before,
+// and returns why pins may be missing: no device tree, an unreadable one,
+// one without pins or the problems of InitErrors. The pins that could be
+// made are kept whatever the error.
+//
+// Without Init the pin map is built from /proc/device-tree on first use,
+// silently.
+func Init(opts ...Option) error {
+	var c initConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.setPrefix {
+		SetDebugPrefix(c.prefix)
+	}
+	if c.backend != nil {
+		SetBackend(c.backend)
+	}
+	// Keep the lazy build from replacing this one.
+	initOnce.Do(func() {})
+	t := c.tree
+	switch {
+	case t != nil:
+	case c.fdtPath != "":
+		b, err := ioutil.ReadFile(c.fdtPath)
+		if err != nil {
+			buildRegistry(nil, c.offline)
+			return err
+		}
+		if len(b) < 40 || binary.BigEndian.Uint32(b) != 0xd00dfeed {
+			buildRegistry(nil, c.offline)
+			return fmt.Errorf("%s: not a flattened device tree",
+				c.fdtPath)
+		}
+		t = new(fdt.Tree)
+		if err = t.Parse(b); err != nil {
+			buildRegistry(nil, c.offline)
+			return fmt.Errorf("%s: %v", c.fdtPath, err)
+		}
+	default:
+		if t = fdt.DefaultTree(); t == nil {
+			buildRegistry(nil, c.offline)
+			return fmt.Errorf("no device tree in /proc/device-tree")
+		}
+	}
+	if t.RootNode == nil {
+		buildRegistry(nil, c.offline)
+		return fmt.Errorf("empty device tree")
+	}
+	buildRegistry(t, c.offline)
+	if errs := InitErrors(); len(errs) != 0 {
+		return errorList(errs)
+	}
+	if NumPins() == 0 {
+		return fmt.Errorf("no gpio pins in the device tree")
+	}
+	return nil
+}
+
+// InitFromTree is Init with the given device tree, e.g. one built or
+// modified by the caller.
+func InitFromTree(t *fdt.Tree, opts ...Option) error {
+	if t == nil {
+		t = new(fdt.Tree)
+	}
+	return Init(append(opts, func(c *initConfig) { c.tree = t })...)
+}
+
+// InitFromDTB is Init of the flattened device tree blob at path; add
+// WithOffline to inspect another board's pin map.
+func InitFromDTB(path string, opts ...Option) error {
+	return Init(append(opts, WithFDT(path))...)
+}
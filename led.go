@@ -0,0 +1,200 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Step is a stretch of an LED pattern.
+type Step struct {
+	On       bool
+	Duration time.Duration
+}
+
+// LED pattern presets.
+var (
+	Heartbeat = []Step{
+		{true, 70 * time.Millisecond},
+		{false, 150 * time.Millisecond},
+		{true, 70 * time.Millisecond},
+		{false, 710 * time.Millisecond},
+	}
+	SOS = sosPattern()
+)
+
+func sosPattern() (l []Step) {
+	const unit = 150 * time.Millisecond
+	for _, letter := range []string{"...", "---", "..."} {
+		for _, c := range letter {
+			on := unit
+			if c == '-' {
+				on = 3 * unit
+			}
+			l = append(l, Step{true, on}, Step{false, unit})
+		}
+		l[len(l)-1].Duration = 3 * unit
+	}
+	l[len(l)-1].Duration = 7 * unit
+	return
+}
+
+// Led is an LED on an output pin, on being its logical high. Patterns of
+// every Led are run by one shared goroutine; write errors are left for
+// FailedPins.
+type Led struct {
+	Pin *Pin
+
+	// Guarded by leds.mu.
+	steps []Step
+	step  int
+	next  time.Time
+	index int
+}
+
+// NewLed turns the LED off.
+func NewLed(p *Pin) (*Led, error) {
+	if err := p.SetDirection("low"); err != nil {
+		return nil, err
+	}
+	return &Led{Pin: p, index: -1}, nil
+}
+
+func (l *Led) String() string { return fmt.Sprintf("led %s", l.Pin.Name) }
+
+// On stops any pattern and turns the LED on.
+func (l *Led) On() error {
+	leds.cancel(l)
+	return l.Pin.SetValue(true)
+}
+
+// Off stops any pattern and turns the LED off.
+func (l *Led) Off() error {
+	leds.cancel(l)
+	return l.Pin.SetValue(false)
+}
+
+// Blink repeats on then off.
+func (l *Led) Blink(on, off time.Duration) error {
+	return l.Pattern([]Step{{true, on}, {false, off}})
+}
+
+// Pattern repeats the steps, e.g. Heartbeat, until another call.
+func (l *Led) Pattern(steps []Step) error {
+	var total time.Duration
+	for _, s := range steps {
+		if s.Duration < 0 {
+			return fmt.Errorf("%s: negative step", l)
+		}
+		total += s.Duration
+	}
+	if total <= 0 {
+		return fmt.Errorf("%s: pattern of no duration", l)
+	}
+	leds.cancel(l)
+	if err := l.Pin.SetValue(steps[0].On); err != nil {
+		return err
+	}
+	leds.start(l, append([]Step(nil), steps...))
+	return nil
+}
+
+// The shared LED scheduler, a heap of LEDs by the time of their next step.
+var leds ledScheduler
+
+type ledScheduler struct {
+	mu   sync.Mutex
+	h    ledHeap
+	wake chan struct{}
+}
+
+func (s *ledScheduler) cancel(l *Led) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l.index >= 0 {
+		heap.Remove(&s.h, l.index)
+	}
+	l.steps = nil
+}
+
+func (s *ledScheduler) start(l *Led, steps []Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l.steps, l.step = steps, 0
+	l.next = time.Now().Add(steps[0].Duration)
+	heap.Push(&s.h, l)
+	if s.wake == nil {
+		s.wake = make(chan struct{}, 1)
+		go s.run()
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ledScheduler) run() {
+	t := time.NewTimer(time.Hour)
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		for len(s.h) != 0 && !s.h[0].next.After(now) {
+			l := s.h[0]
+			// Skip zero length steps.
+			for {
+				l.step = (l.step + 1) % len(l.steps)
+				l.next = l.next.Add(l.steps[l.step].Duration)
+				if l.steps[l.step].Duration != 0 {
+					break
+				}
+			}
+			// Drop the steps missed rather than rush to catch up.
+			if l.next.Before(now) {
+				l.next = now.Add(l.steps[l.step].Duration)
+			}
+			heap.Fix(&s.h, 0)
+			// Written under the lock so a step can't undo a later
+			// On or Off.
+			l.Pin.SetValue(l.steps[l.step].On)
+		}
+		wait := time.Hour
+		if len(s.h) != 0 {
+			wait = time.Until(s.h[0].next)
+		}
+		s.mu.Unlock()
+		resetTimer(t, wait)
+		select {
+		case <-t.C:
+		case <-s.wake:
+		}
+	}
+}
+
+type ledHeap []*Led
+
+func (h ledHeap) Len() int           { return len(h) }
+func (h ledHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+
+func (h ledHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *ledHeap) Push(x interface{}) {
+	l := x.(*Led)
+	l.index = len(*h)
+	*h = append(*h, l)
+}
+
+func (h *ledHeap) Pop() interface{} {
+	old := *h
+	l := old[len(old)-1]
+	l.index = -1
+	*h = old[:len(old)-1]
+	return l
+}
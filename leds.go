@@ -0,0 +1,102 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/fdt"
+)
+
+// A gpio controller of the device tree and its bank.
+type controller struct {
+	n    *fdt.Node
+	bank string
+}
+
+// The banked controllers by phandle, while building the pin map.
+var controllers map[uint32]controller
+
+// gatherLedsKeys adds pins for the LEDs of a gpio-leds node and the buttons
+// of a gpio-keys or gpio-keys-polled node. They go by their label, or
+// failing that their node's name, unless a pin already does; LEDs default
+// to their default-state, buttons to input. Lines the kernel's drivers
+// hold are left to them rather than reported busy.
+func gatherLedsKeys(n *fdt.Node, name string, value string) {
+	mode := ""
+	for _, compatible := range initTree.PropStringSlice([]byte(value)) {
+		switch compatible {
+		case "gpio-leds":
+			mode = "output-low"
+		case "gpio-keys", "gpio-keys-polled":
+			mode = "input"
+		}
+	}
+	if mode == "" {
+		return
+	}
+	for _, c := range n.Children {
+		path := nodePaths[c]
+		cells := initTree.PropUint32Slice(c.Properties["gpios"])
+		if len(cells) == 0 {
+			continue
+		}
+		ctl, f := controllers[cells[0]]
+		if !f {
+			initError(fmt.Errorf("%s: gpios of no banked controller", path))
+			continue
+		}
+		ncells := 2
+		if b, f := ctl.n.Properties["#gpio-cells"]; f && len(b) == 4 {
+			ncells = int(initTree.PropUint32(b))
+		}
+		if ncells == 0 || len(cells) < 1+ncells {
+			initError(fmt.Errorf("%s: malformed gpios", path))
+			continue
+		}
+		pn := strings.Split(c.Name, "@")[0]
+		if b, f := c.Properties["label"]; f && len(b) > 1 {
+			pn = initTree.PropString(b)
+		}
+		regMu.RLock()
+		_, f = pins[pn]
+		regMu.RUnlock()
+		if f {
+			continue
+		}
+		m := mode
+		if b, f := c.Properties["default-state"]; f && mode != "input" {
+			switch initTree.PropString(b) {
+			case "on":
+				m = "output-high"
+			case "keep":
+				m = ""
+			}
+		}
+		p, err := newPin(pn, m, ctl.bank, strconv.Itoa(int(cells[1])))
+		p.NodePath = path
+		p.Label = pn
+		if errors.Is(err, ErrBusy) {
+			continue
+		}
+		var flags uint32
+		if ncells > 1 {
+			flags = cells[2]
+		}
+		if err == nil && flags&1 != 0 {
+			err = p.initActiveLow()
+		}
+		if err == nil {
+			p.initBias(flagsBias(flags))
+			p.initDrive(flagsDrive(flags))
+		}
+		if err != nil {
+			initError(fmt.Errorf("%s: %s", path, err))
+		}
+	}
+}
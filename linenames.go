@@ -0,0 +1,83 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+
+	"github.com/platinasystems/fdt"
+)
+
+// gatherLineNames names the lines of a controller node, of the given bank,
+// by its gpio-line-names.
+func gatherLineNames(n *fdt.Node, bank string) {
+	b, f := n.Properties["gpio-line-names"]
+	if !f {
+		return
+	}
+	base, _ := bankBase(bank)
+	for i, name := range initTree.PropStringSlice(b) {
+		if name == "" {
+			continue
+		}
+		if err := addLineName(name, base+i, nodePaths[n]); err != nil {
+			initError(fmt.Errorf("%s: %s", nodePaths[n], err))
+		}
+	}
+}
+
+// gatherChipLineNames names lines by the names their drivers give them, as
+// seen through the character devices.
+func gatherChipLineNames() {
+	devs := make(map[string]int)
+	for _, c := range Chips() {
+		if c.Dev != "" {
+			devs[c.Dev] = c.Base
+		}
+	}
+	regMu.RLock()
+	for bank, dev := range bankDevs {
+		if _, f := devs[dev]; !f {
+			devs[dev] = GpioBankToBase[bank]
+		}
+	}
+	regMu.RUnlock()
+	for dev, base := range devs {
+		names, _ := cdevLineNames(prefix + "/dev/" + dev)
+		for i, name := range names {
+			if name != "" {
+				addLineName(name, base+i, "")
+			}
+		}
+	}
+}
+
+// addLineName gives the gpio's pin the line name or, if the gpio has no
+// configured pin, makes one by that name just for FindPin; it isn't added
+// to the pin map, nor exported.
+func addLineName(name string, gpio int, path string) error {
+	regMu.Lock()
+	defer regMu.Unlock()
+	for _, p := range pins {
+		if p.Gpio == gpio && p.onChip() {
+			if p.LineName == "" {
+				p.LineName = name
+			}
+			if name != p.Name {
+				lineNames[name] = p
+			}
+			return nil
+		}
+	}
+	if p, f := pins[name]; f {
+		return fmt.Errorf("line name %s of gpio %d is pin of gpio %d",
+			name, gpio, p.Gpio)
+	}
+	if _, f := lineNames[name]; !f {
+		lineNames[name] = &Pin{Gpio: gpio, Name: name, Label: name,
+			LineName: name, NodePath: path}
+	}
+	return nil
+}
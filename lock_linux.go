@@ -0,0 +1,55 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// LockDir holds the pin lock files of TryClaim.
+var LockDir = "/run/gpio"
+
+// lockPin flocks the pin's lock file and writes the process's pid and the
+// owner to it.
+func lockPin(p *Pin, owner string) (*os.File, error) {
+	dir := prefix + LockDir
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	fn := fmt.Sprintf("%s/gpio%d.lock", dir, p.Gpio)
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		e := &ClaimError{Pin: p, Owner: "another process"}
+		if b, err := ioutil.ReadAll(f); err == nil {
+			l := strings.SplitN(strings.TrimSpace(string(b)), " ", 2)
+			if pid, err := strconv.Atoi(l[0]); err == nil && len(l) == 2 {
+				e.PID, e.Owner = pid, l[1]
+			}
+		}
+		f.Close()
+		return nil, e
+	}
+	if err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "flock", Path: fn, Err: err}
+	}
+	if err = f.Truncate(0); err == nil {
+		_, err = fmt.Fprintf(f, "%d %s\n", os.Getpid(), owner)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
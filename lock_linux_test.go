@@ -0,0 +1,50 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+func TestTryClaim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gpio.SetDebugPrefix(dir)
+	defer func() {
+		gpio.SetDebugPrefix("")
+		os.RemoveAll(dir)
+	}()
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 4, Name: "mux_sel", Backend: be}
+	if err = p.TryClaim("muxd"); err != nil {
+		t.Fatal(err)
+	}
+	// The same line of another process, as far as the flock goes.
+	other := &gpio.Pin{Gpio: 4, Name: "mux_sel", Backend: be}
+	err = other.TryClaim("i2cd")
+	var ce *gpio.ClaimError
+	if !errors.As(err, &ce) || ce.Owner != "muxd" ||
+		ce.PID != os.Getpid() {
+		t.Fatalf("contended TryClaim: %v", err)
+	}
+	if o := other.Owner(); o != "" {
+		t.Errorf("failed TryClaim left a claim of %q", o)
+	}
+	if err = p.Unclaim("muxd"); err != nil {
+		t.Fatal(err)
+	}
+	if err = other.TryClaim("i2cd"); err != nil {
+		t.Fatalf("TryClaim after release: %v", err)
+	}
+	other.Unclaim("i2cd")
+}
@@ -0,0 +1,20 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import (
+	"errors"
+	"os"
+)
+
+// LockDir holds the pin lock files of TryClaim.
+var LockDir = "/run/gpio"
+
+func lockPin(*Pin, string) (*os.File, error) {
+	return nil, errors.New("gpio lock files require linux")
+}
@@ -0,0 +1,43 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+var (
+	logMu  sync.RWMutex
+	logger *slog.Logger
+)
+
+// SetLogger has the package log its exports, direction changes, writes,
+// watches and init failures to l, each with the pin's name and gpio as
+// attributes; writes and direction changes at debug level. A nil logger,
+// the default, logs nothing.
+func SetLogger(l *slog.Logger) {
+	logMu.Lock()
+	logger = l
+	logMu.Unlock()
+}
+
+func getLogger() *slog.Logger {
+	logMu.RLock()
+	defer logMu.RUnlock()
+	return logger
+}
+
+// log logs the message about the pin, if logging is set and enabled at the
+// level.
+func (p *Pin) log(level slog.Level, msg string, args ...interface{}) {
+	l := getLogger()
+	if l == nil || !l.Enabled(context.Background(), level) {
+		return
+	}
+	l.Log(context.Background(), level, msg,
+		append([]interface{}{"name", p.Name, "gpio", p.Gpio}, args...)...)
+}
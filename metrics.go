@@ -0,0 +1,118 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var metricLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`,
+	"\n", `\n`)
+
+// WriteMetrics writes the value, with direction as a label, and Counters of
+// each configured pin in the Prometheus text exposition format. Pins whose
+// value or direction can't be read are left out of gpio_value.
+func WriteMetrics(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	l := SortedPins()
+	labels := func(p *Pin) string {
+		return fmt.Sprintf(`name="%s",gpio="%d"`,
+			metricLabelReplacer.Replace(p.Name), p.Gpio)
+	}
+	fmt.Fprint(b, "# HELP gpio_value Current logical value of the gpio.\n",
+		"# TYPE gpio_value gauge\n")
+	for _, p := range l {
+		dir, err := p.Direction()
+		if err != nil {
+			continue
+		}
+		v, err := p.Value()
+		if err != nil {
+			continue
+		}
+		x := 0
+		if v {
+			x = 1
+		}
+		fmt.Fprintf(b, "gpio_value{%s,direction=\"%s\"} %d\n",
+			labels(p), dir, x)
+	}
+	counters := make([]PinCounters, len(l))
+	for i, p := range l {
+		counters[i] = p.Counters()
+	}
+	for _, m := range []struct {
+		name, help string
+		n          func(c PinCounters) uint64
+	}{
+		{"gpio_toggles_total", "Writes changing the gpio's value.",
+			func(c PinCounters) uint64 { return c.Toggles }},
+		{"gpio_edges_total", "Edges delivered to watches of the gpio.",
+			func(c PinCounters) uint64 { return c.Edges }},
+		{"gpio_errors_total", "Failed operations on the gpio.",
+			func(c PinCounters) uint64 { return c.Errors }},
+	} {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n",
+			m.name, m.help, m.name)
+		for i, p := range l {
+			fmt.Fprintf(b, "%s{%s} %d\n", m.name, labels(p),
+				m.n(counters[i]))
+		}
+	}
+	return b.Flush()
+}
+
+// MetricsHandler serves WriteMetrics for a Prometheus scrape; package
+// promgpio has the same metrics for a prometheus.Registerer.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type",
+			"text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w)
+	})
+}
+
+// PinCounters are the activity of a pin since the process started.
+type PinCounters struct {
+	// Writes by SetValue, Toggle, Pulse and SetDirection "high" or "low"
+	// that changed its value; the first write is only counted as a
+	// change by Toggle and Pulse.
+	Toggles uint64
+	// Edges delivered to its watches, by Watch or an EventMonitor.
+	Edges uint64
+	// Failed operations.
+	Errors uint64
+}
+
+// Counters returns the pin's activity counts, e.g. for a Prometheus
+// collector.
+func (p *Pin) Counters() PinCounters {
+	mu.Lock()
+	defer mu.Unlock()
+	return PinCounters{p.toggles, p.edges, uint64(p.nerr)}
+}
+
+// noteWrite counts a write of v that changed the value, as known by toggled
+// or from the last write.
+func (p *Pin) noteWrite(v, toggled bool) {
+	mu.Lock()
+	if toggled || (p.written && p.wrote != v) {
+		p.toggles++
+	}
+	p.wrote, p.written = v, true
+	mu.Unlock()
+}
+
+// noteEdge counts an edge delivered to a watch and keeps it for History.
+func (p *Pin) noteEdge(e EdgeEvent) {
+	mu.Lock()
+	p.edges++
+	p.hist.add(e)
+	mu.Unlock()
+}
@@ -0,0 +1,79 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"fmt"
+)
+
+// Mirror drives dst to the value of src, as a buffer in software, e.g. to
+// route a signal during bring-up, until ctx is done, when it returns nil.
+// It makes dst an output, at src's value, if it isn't one, and watches src,
+// replacing any watch of it and leaving it unwatched. It stops at the first
+// failure to read src or write dst, returning it; see MirrorOnError.
+func Mirror(ctx context.Context, src, dst *Pin) error {
+	return MirrorOnError(ctx, src, dst, nil)
+}
+
+// MirrorOnError is Mirror calling onError, if not nil, with each failure
+// once it's mirroring, to read src, write dst or of src's watch, to report
+// it. It carries on if onError returns true, watching src again if the
+// watch ended, and otherwise stops with the error.
+func MirrorOnError(ctx context.Context, src, dst *Pin,
+	onError func(err error) bool) error {
+	fail := func(err error) error {
+		if onError != nil && onError(err) {
+			return nil
+		}
+		return err
+	}
+	v, err := src.Value()
+	if err != nil {
+		return err
+	}
+	if dir, err := dst.Direction(); err != nil || dir != "out" {
+		dir = "low"
+		if v {
+			dir = "high"
+		}
+		if err = dst.SetDirection(dir); err != nil {
+			return fmt.Errorf("%s: mirror of %s: %w", dst, src, err)
+		}
+	}
+	defer src.Watch(EdgeNone)
+	for {
+		c, err := src.Watch(EdgeBoth)
+		if err != nil {
+			return err
+		}
+		// Catch up with any change before the watch.
+		if v, err = src.Value(); err == nil {
+			err = dst.SetValue(v)
+		}
+		if err != nil {
+			if err = fail(err); err != nil {
+				return err
+			}
+		}
+		for ended := false; !ended; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case e, ok := <-c:
+				if ok {
+					err = dst.SetValue(e.Value)
+				} else {
+					err, ended = src.watchEnded(), true
+				}
+				if err != nil {
+					if err = fail(err); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+// failBackend is a gpiotest backend whose writes of line fail while failing
+// is set.
+type failBackend struct {
+	*gpiotest.Backend
+	line    int
+	mu      sync.Mutex
+	failing bool
+}
+
+var errWrite = errors.New("write failed")
+
+func (b *failBackend) Write(p *gpio.Pin, v bool) error {
+	b.mu.Lock()
+	failing := b.failing
+	b.mu.Unlock()
+	if p.Gpio == b.line && failing {
+		return errWrite
+	}
+	return b.Backend.Write(p, v)
+}
+
+func (b *failBackend) setFailing(f bool) {
+	b.mu.Lock()
+	b.failing = f
+	b.mu.Unlock()
+}
+
+// waitLevel waits for the backend's line to be at the level.
+func waitLevel(t *testing.T, b *gpiotest.Backend, gpio int, level bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); b.Level(gpio) != level; {
+		if time.Now().After(deadline) {
+			t.Fatalf("gpio%d not %v", gpio, level)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	be := gpiotest.New()
+	src := &gpio.Pin{Gpio: 1, Name: "src", Backend: be}
+	dst := &gpio.Pin{Gpio: 2, Name: "dst", Backend: be}
+	be.Inject(1, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- gpio.Mirror(ctx, src, dst) }()
+	waitLevel(t, be, 2, true)
+	if dir, _ := dst.Direction(); dir != "out" {
+		t.Errorf("dst direction %q", dir)
+	}
+	for _, v := range []bool{false, true, false} {
+		be.Inject(1, v)
+		waitLevel(t, be, 2, v)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Mirror: %v", err)
+	}
+	be.Inject(1, true)
+	time.Sleep(10 * time.Millisecond)
+	if be.Level(2) {
+		t.Error("dst follows src after cancel")
+	}
+}
+
+func TestMirrorStop(t *testing.T) {
+	be := &failBackend{Backend: gpiotest.New(), line: 2}
+	src := &gpio.Pin{Gpio: 1, Name: "src", Backend: be}
+	dst := &gpio.Pin{Gpio: 2, Name: "dst", Backend: be}
+	done := make(chan error)
+	go func() { done <- gpio.Mirror(context.Background(), src, dst) }()
+	waitLevel(t, be.Backend, 2, false)
+	be.setFailing(true)
+	be.Inject(1, true)
+	select {
+	case err := <-done:
+		if !errors.Is(err, errWrite) {
+			t.Errorf("Mirror: %v, want %v", err, errWrite)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Mirror didn't stop on a write error")
+	}
+}
+
+func TestMirrorOnError(t *testing.T) {
+	be := &failBackend{Backend: gpiotest.New(), line: 2}
+	src := &gpio.Pin{Gpio: 1, Name: "src", Backend: be}
+	dst := &gpio.Pin{Gpio: 2, Name: "dst", Backend: be}
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go gpio.MirrorOnError(ctx, src, dst, func(err error) bool {
+		errs <- err
+		return true
+	})
+	waitLevel(t, be.Backend, 2, false)
+	be.setFailing(true)
+	be.Inject(1, true)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errWrite) {
+			t.Errorf("reported %v, want %v", err, errWrite)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write error not reported")
+	}
+	be.setFailing(false)
+	be.Inject(1, false)
+	be.Inject(1, true)
+	waitLevel(t, be.Backend, 2, true)
+}
@@ -0,0 +1,273 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/platinasystems/fdt"
+)
+
+// Mmap reads and writes the registers of the gpio controllers of the SoCs
+// in socRegisters directly through /dev/mem, for toggles well under a
+// microsecond. Controllers are found by their device tree compatible
+// strings and pins on others fail.
+//
+// The kernel's driver is unaware of these accesses. Read-modify-writes are
+// serialized within the process but may race the kernel changing another
+// line of the same bank, so don't mix Mmap with sysfs or character device
+// use of a bank's outputs. It can't watch edges, nor emulate open drain or
+// source outputs.
+var Mmap Backend = mmapBackend{}
+
+type mmapBackend struct{}
+
+// socRegs are the byte offsets of a gpio bank's 32 bit registers.
+type socRegs struct {
+	// Output levels, directions and input levels.
+	out, dir, in uintptr
+	// Whether a set direction bit makes the line an output.
+	dirOut bool
+	// Register inverting the input levels, if the bank has one.
+	inPol    uintptr
+	hasInPol bool
+}
+
+var (
+	imxRegs = &socRegs{out: 0x00, dir: 0x04, in: 0x08, dirOut: true}
+	// Marvell's data out enable is active low.
+	mvebuRegs = &socRegs{out: 0x00, dir: 0x04, in: 0x10,
+		inPol: 0x0c, hasInPol: true}
+)
+
+// socRegisters are the register layouts by controller compatible string.
+var socRegisters = map[string]*socRegs{
+	"fsl,imx21-gpio":          imxRegs,
+	"fsl,imx31-gpio":          imxRegs,
+	"fsl,imx35-gpio":          imxRegs,
+	"fsl,imx6q-gpio":          imxRegs,
+	"fsl,imx7d-gpio":          imxRegs,
+	"fsl,imx8mq-gpio":         imxRegs,
+	"marvell,orion-gpio":      mvebuRegs,
+	"marvell,armada-370-gpio": mvebuRegs,
+	"marvell,armadaxp-gpio":   mvebuRegs,
+}
+
+// mmapChip is a gpio bank of a supported controller.
+type mmapChip struct {
+	base int
+	phys uint64
+	regs *socRegs
+	// Registers once mapped.
+	mem []byte
+}
+
+// Supported banks found in the device tree, and a lock serializing their
+// mapping and read-modify-writes.
+var (
+	mmapMu    sync.Mutex
+	mmapChips []*mmapChip
+)
+
+func resetMmapChips() {
+	mmapMu.Lock()
+	mmapChips = nil
+	mmapMu.Unlock()
+}
+
+// gatherMmapChip notes the registers of a controller node, of the given
+// bank, if it's of a supported SoC.
+func gatherMmapChip(n *fdt.Node, bank string) {
+	var regs *socRegs
+	for _, c := range initTree.PropStringSlice(n.Properties["compatible"]) {
+		if regs = socRegisters[c]; regs != nil {
+			break
+		}
+	}
+	if regs == nil {
+		return
+	}
+	phys, ok := nodeRegAddr(n)
+	if !ok {
+		initError(fmt.Errorf("%s: no cpu address for reg", nodePaths[n]))
+		return
+	}
+	base, _ := bankBase(bank)
+	mmapMu.Lock()
+	mmapChips = append(mmapChips, &mmapChip{
+		base: base,
+		phys: phys,
+		regs: regs,
+	})
+	mmapMu.Unlock()
+}
+
+func nodeCells(n *fdt.Node, name string, def int) int {
+	if n != nil {
+		if b, f := n.Properties[name]; f && len(b) == 4 {
+			return int(initTree.PropUint32(b))
+		}
+	}
+	return def
+}
+
+func cellsValue(cells []uint32) (v uint64) {
+	for _, c := range cells {
+		v = v<<32 | uint64(c)
+	}
+	return
+}
+
+// nodeRegAddr returns the cpu physical address of the node's first reg,
+// translated through the ranges of the buses above it.
+func nodeRegAddr(n *fdt.Node) (addr uint64, ok bool) {
+	bus := nodeParents[n]
+	ac, sc := nodeCells(bus, "#address-cells", 2),
+		nodeCells(bus, "#size-cells", 1)
+	reg := initTree.PropUint32Slice(n.Properties["reg"])
+	if ac < 1 || ac > 2 || len(reg) < ac+sc {
+		return
+	}
+	addr = cellsValue(reg[:ac])
+	for ; bus != nil && nodeParents[bus] != nil; bus = nodeParents[bus] {
+		b, f := bus.Properties["ranges"]
+		if !f {
+			return 0, false
+		}
+		if len(b) == 0 {
+			continue
+		}
+		cac := nodeCells(bus, "#address-cells", 2)
+		pac := nodeCells(nodeParents[bus], "#address-cells", 2)
+		bsc := nodeCells(bus, "#size-cells", 1)
+		r, w := initTree.PropUint32Slice(b), cac+pac+bsc
+		found := false
+		for i := 0; i+w <= len(r); i += w {
+			child := cellsValue(r[i : i+cac])
+			parent := cellsValue(r[i+cac : i+cac+pac])
+			size := cellsValue(r[i+cac+pac : i+w])
+			if addr >= child && addr-child < size {
+				addr, found = parent+addr-child, true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return addr, true
+}
+
+// chip returns the pin's bank, mapping it on first use, and the pin's bit.
+func (mmapBackend) chip(p *Pin) (*mmapChip, uint32, error) {
+	mmapMu.Lock()
+	defer mmapMu.Unlock()
+	for _, c := range mmapChips {
+		if p.Gpio < c.base || p.Gpio >= c.base+32 {
+			continue
+		}
+		if c.mem == nil {
+			mem, err := mapRegs(c.phys)
+			if err != nil {
+				return nil, 0, err
+			}
+			c.mem = mem
+		}
+		return c, 1 << uint(p.Gpio-c.base), nil
+	}
+	return nil, 0, fmt.Errorf("%s: not on a memory mapped controller", p)
+}
+
+func (c *mmapChip) reg(off uintptr) *uint32 {
+	return (*uint32)(unsafe.Pointer(&c.mem[off]))
+}
+
+func (c *mmapChip) get(off uintptr, bit uint32) bool {
+	return atomic.LoadUint32(c.reg(off))&bit != 0
+}
+
+func (c *mmapChip) set(off uintptr, bit uint32, v bool) {
+	mmapMu.Lock()
+	defer mmapMu.Unlock()
+	r := c.reg(off)
+	if v {
+		atomic.StoreUint32(r, atomic.LoadUint32(r)|bit)
+	} else {
+		atomic.StoreUint32(r, atomic.LoadUint32(r)&^bit)
+	}
+}
+
+func (b mmapBackend) Export(p *Pin) error {
+	_, _, err := b.chip(p)
+	return err
+}
+
+func (mmapBackend) Unexport(*Pin) error { return nil }
+
+func (b mmapBackend) IsExported(p *Pin) bool {
+	_, _, err := b.chip(p)
+	return err == nil
+}
+
+func (b mmapBackend) Direction(p *Pin) (dir string, err error) {
+	c, bit, err := b.chip(p)
+	if err != nil {
+		return
+	}
+	dir = "in"
+	if c.get(c.regs.dir, bit) == c.regs.dirOut {
+		dir = "out"
+	}
+	return
+}
+
+func (b mmapBackend) SetDirection(p *Pin, dir string) error {
+	c, bit, err := b.chip(p)
+	if err != nil {
+		return err
+	}
+	switch dir {
+	case "in":
+		c.set(c.regs.dir, bit, !c.regs.dirOut)
+		return nil
+	case "out", "low", "high":
+		// Set the level before enabling the output so it doesn't glitch.
+		c.set(c.regs.out, bit, (dir == "high") != p.ActiveLow)
+		c.set(c.regs.dir, bit, c.regs.dirOut)
+		return nil
+	}
+	return fmt.Errorf("%w %q", ErrBadDirection, dir)
+}
+
+func (b mmapBackend) Read(p *Pin) (bool, error) {
+	c, bit, err := b.chip(p)
+	if err != nil {
+		return false, err
+	}
+	v := c.get(c.regs.in, bit)
+	if c.regs.hasInPol && c.get(c.regs.inPol, bit) {
+		v = !v
+	}
+	return v != p.ActiveLow, nil
+}
+
+func (b mmapBackend) Write(p *Pin, v bool) error {
+	c, bit, err := b.chip(p)
+	if err != nil {
+		return err
+	}
+	c.set(c.regs.out, bit, v != p.ActiveLow)
+	return nil
+}
+
+func (mmapBackend) Watch(p *Pin, edge Edge) (<-chan Event, error) {
+	if edge == EdgeNone {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s: memory mapped pins can't be watched", p)
+}
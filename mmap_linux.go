@@ -0,0 +1,32 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"os"
+	"syscall"
+)
+
+// Bytes of a bank's registers to map.
+const mmapRegsSize = 0x20
+
+// mapRegs maps the registers at the physical address through /dev/mem and
+// returns them from that address on.
+func mapRegs(phys uint64) ([]byte, error) {
+	page := uint64(os.Getpagesize())
+	off := phys &^ (page - 1)
+	size := (phys - off + mmapRegsSize + page - 1) &^ (page - 1)
+	f, err := os.OpenFile(prefix+"/dev/mem", os.O_RDWR|os.O_SYNC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mem, err := syscall.Mmap(int(f.Fd()), int64(off), int(size),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.SyscallError{Syscall: "mmap", Err: err}
+	}
+	return mem[phys-off:], nil
+}
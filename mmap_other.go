@@ -0,0 +1,14 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import "errors"
+
+func mapRegs(uint64) ([]byte, error) {
+	return nil, errors.New("gpio register mapping requires linux")
+}
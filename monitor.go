@@ -0,0 +1,234 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// EventMonitor multiplexes the edges of many pins through one epoll loop and
+// goroutine onto a single channel. Pins of backends other than the builtin
+// ones are watched with Pin.Watch and each costs a forwarding goroutine.
+//
+// A pin should be in at most one monitor and not also be watched directly.
+type EventMonitor struct {
+	c     chan Event
+	pl    *poller
+	stopc chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	sources map[int]*monitored
+	pins    map[*Pin]*monitored
+}
+
+type monitored struct {
+	p   *Pin
+	src *edgeSource
+	// Backend that armed src, or nil for a forwarded Pin.Watch.
+	armer edgeArmer
+	fwd   chan struct{}
+}
+
+// Events buffered by a monitor.
+const monitorDepth = 256
+
+func NewEventMonitor() (*EventMonitor, error) {
+	pl, err := newPoller()
+	if err != nil {
+		return nil, err
+	}
+	m := &EventMonitor{
+		c:       make(chan Event, monitorDepth),
+		pl:      pl,
+		stopc:   make(chan struct{}),
+		done:    make(chan struct{}),
+		sources: make(map[int]*monitored),
+		pins:    make(map[*Pin]*monitored),
+	}
+	go m.loop()
+	return m, nil
+}
+
+// Events returns the channel of the monitored pins' edges, which is closed
+// by Close.
+func (m *EventMonitor) Events() <-chan Event { return m.c }
+
+// Add starts reporting the given edges of the pin, replacing any it was
+// added with before.
+func (m *EventMonitor) Add(p *Pin, edge Edge) error {
+	if edge == EdgeNone {
+		return m.Remove(p)
+	}
+	if err := m.Remove(p); err != nil {
+		return err
+	}
+	a, f := p.backend().(edgeArmer)
+	if !f {
+		return m.forward(p, edge)
+	}
+	p.op.Lock()
+	src, err := a.armEdge(p, edge)
+	if err == nil {
+		m.mu.Lock()
+		err = m.pl.add(src.fd, src.events)
+		if err == nil {
+			x := &monitored{p: p, src: src, armer: a}
+			m.sources[src.fd] = x
+			m.pins[p] = x
+		}
+		m.mu.Unlock()
+		if err != nil {
+			src.cleanup()
+			a.armEdge(p, EdgeNone)
+		}
+	}
+	p.op.Unlock()
+	p.record("watch", &err)
+	return err
+}
+
+func (m *EventMonitor) forward(p *Pin, edge Edge) error {
+	c, err := p.Watch(edge)
+	if err != nil {
+		return err
+	}
+	x := &monitored{p: p, fwd: make(chan struct{})}
+	m.mu.Lock()
+	m.pins[p] = x
+	m.mu.Unlock()
+	go func() {
+		defer close(x.fwd)
+		for e := range c {
+			select {
+			case m.c <- e:
+			case <-m.stopc:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Remove stops reporting the pin's edges.
+func (m *EventMonitor) Remove(p *Pin) (err error) {
+	m.mu.Lock()
+	x := m.pins[p]
+	delete(m.pins, p)
+	if x != nil && x.src != nil {
+		delete(m.sources, x.src.fd)
+		m.pl.remove(x.src.fd)
+		x.src.cleanup()
+	}
+	m.mu.Unlock()
+	switch {
+	case x == nil:
+	case x.armer != nil:
+		p.op.Lock()
+		_, err = x.armer.armEdge(p, EdgeNone)
+		p.op.Unlock()
+	default:
+		_, err = p.Watch(EdgeNone)
+		<-x.fwd
+	}
+	return
+}
+
+// Close removes every pin and closes the Events channel.
+func (m *EventMonitor) Close() error {
+	m.mu.Lock()
+	l := make([]*Pin, 0, len(m.pins))
+	for p := range m.pins {
+		l = append(l, p)
+	}
+	m.mu.Unlock()
+	errs := make(PinErrors)
+	for _, p := range l {
+		if err := m.Remove(p); err != nil {
+			errs[p] = err
+		}
+	}
+	close(m.stopc)
+	m.pl.stop()
+	<-m.done
+	m.pl.close()
+	close(m.c)
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// loop reads the polled pins' edges until Close, which closes m.c once it
+// and the forwarders are done so that none sends on it closed.
+func (m *EventMonitor) loop() {
+	realtimeReader()
+	defer close(m.done)
+	type result struct {
+		x      *monitored
+		events []EdgeEvent
+		err    error
+	}
+	for {
+		fds, ok, err := m.pl.wait(m.timeout())
+		if err != nil || !ok {
+			return
+		}
+		var results []result
+		m.mu.Lock()
+		read := make(map[int]bool, len(fds))
+		for _, fd := range fds {
+			if x := m.sources[fd]; x != nil {
+				r := result{x: x}
+				r.events, r.err = x.src.read()
+				if r.err == nil {
+					r.events = x.src.db.filter(r.events, time.Now())
+				}
+				results = append(results, r)
+				read[fd] = true
+			}
+		}
+		// Debounced pins without edges may still have one to report.
+		for fd, x := range m.sources {
+			if x.src.db != nil && !read[fd] {
+				events := x.src.db.filter(nil, time.Now())
+				if len(events) > 0 {
+					results = append(results, result{x: x, events: events})
+				}
+			}
+		}
+		m.mu.Unlock()
+		for _, r := range results {
+			if r.err != nil {
+				err = r.err
+				r.x.p.record("event monitor", &err)
+				m.Remove(r.x.p)
+				continue
+			}
+			for _, e := range r.events {
+				select {
+				case m.c <- Event{Pin: r.x.p, EdgeEvent: e}:
+					r.x.p.noteEdge(e)
+				case <-m.stopc:
+					return
+				}
+			}
+		}
+	}
+}
+
+// timeout returns the soonest timeout of the debounced pins.
+func (m *EventMonitor) timeout() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now, t := time.Now(), time.Duration(-1)
+	for _, x := range m.sources {
+		if d := x.src.db.timeout(now); d >= 0 && (t < 0 || d < t) {
+			t = d
+		}
+	}
+	return t
+}
@@ -0,0 +1,262 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package mqtt is a gpio.EventSink publishing pin events to an MQTT broker,
+// each to a topic of the pin's name, e.g.
+//
+//	pub, err := mqtt.Dial("tcp", "broker:1883",
+//		mqtt.Options{ClientID: "bmc0", Prefix: "bmc0/gpio"})
+//	go gpio.ForwardEvents(m.Events(), logError, pub)
+//
+// publishes the JSON of each gpio.Event to bmc0/gpio/<pin>. It's a minimal
+// MQTT 3.1.1 client: QoS 0 publishes only, with a keep-alive ping and no
+// subscriptions. A lost connection fails the sends until Close; dial again
+// to reconnect.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+// Options of the connection; all are optional.
+type Options struct {
+	ClientID string
+	Username string
+	Password string
+	// Topic prefix of the pin names, without its trailing slash.
+	Prefix string
+	// Publish the events retained, so that subscribers see each pin's
+	// last change.
+	Retain bool
+	// Default 30s.
+	KeepAlive time.Duration
+}
+
+// Packet types, in the high nibble of the fixed header.
+const (
+	connect    = 0x10
+	connack    = 0x20
+	publish    = 0x30
+	pingreq    = 0xc0
+	disconnect = 0xe0
+)
+
+// Publisher is an EventSink of an MQTT connection; it's safe for concurrent
+// use.
+type Publisher struct {
+	opts Options
+	conn net.Conn
+	stop chan struct{}
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+var _ gpio.EventSink = (*Publisher)(nil)
+
+// ErrClosed is the error of sends after Close.
+var ErrClosed = errors.New("mqtt: publisher closed")
+
+// Dial connects and waits for the broker to accept the connection.
+func Dial(network, addr string, opts Options) (*Publisher, error) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 30 * time.Second
+	}
+	conn, err := net.DialTimeout(network, addr, opts.KeepAlive)
+	if err != nil {
+		return nil, err
+	}
+	pub := &Publisher{
+		opts: opts,
+		conn: conn,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(opts.KeepAlive))
+	if err = pub.connect(r); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt: %s: %v", addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	go pub.read(r)
+	go pub.ping()
+	return pub, nil
+}
+
+func (pub *Publisher) connect(r *bufio.Reader) error {
+	var b []byte
+	b = appendString(b, "MQTT")
+	// Protocol level 4, 3.1.1, and a clean session.
+	flags := byte(0x02)
+	if pub.opts.Username != "" {
+		flags |= 0x80
+	}
+	if pub.opts.Password != "" {
+		flags |= 0x40
+	}
+	b = append(b, 4, flags)
+	secs := pub.opts.KeepAlive / time.Second
+	if secs > 0xffff {
+		secs = 0xffff
+	}
+	b = append(b, byte(secs>>8), byte(secs))
+	b = appendString(b, pub.opts.ClientID)
+	if pub.opts.Username != "" {
+		b = appendString(b, pub.opts.Username)
+	}
+	if pub.opts.Password != "" {
+		b = appendString(b, pub.opts.Password)
+	}
+	if err := pub.write(connect, b); err != nil {
+		return err
+	}
+	kind, body, err := readPacket(r)
+	switch {
+	case err != nil:
+		return err
+	case kind&0xf0 != connack || len(body) != 2:
+		return fmt.Errorf("unexpected packet %#x", kind)
+	case body[1] != 0:
+		return fmt.Errorf("connection refused, code %d", body[1])
+	}
+	return nil
+}
+
+// Send publishes the event to the Prefix topic of the pin's name.
+func (pub *Publisher) Send(e gpio.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	topic := e.Pin.Name
+	if pub.opts.Prefix != "" {
+		topic = pub.opts.Prefix + "/" + topic
+	}
+	return pub.Publish(topic, b, pub.opts.Retain)
+}
+
+// Publish sends the payload to the topic at QoS 0.
+func (pub *Publisher) Publish(topic string, payload []byte, retain bool) error {
+	kind := byte(publish)
+	if retain {
+		kind |= 0x01
+	}
+	b := appendString(nil, topic)
+	return pub.write(kind, append(b, payload...))
+}
+
+// write sends a packet unless the connection has failed.
+func (pub *Publisher) write(kind byte, body []byte) error {
+	// The remaining length is a uvarint.
+	const n = 1 + binary.MaxVarintLen32
+	b := make([]byte, n, n+len(body))
+	b[0] = kind
+	b = append(b[:1+binary.PutUvarint(b[1:], uint64(len(body)))], body...)
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	if pub.err != nil {
+		return pub.err
+	}
+	if _, err := pub.conn.Write(b); err != nil {
+		pub.fail(err)
+		return pub.err
+	}
+	return nil
+}
+
+// fail records the connection's first failure and closes it; called with
+// mu held.
+func (pub *Publisher) fail(err error) {
+	if pub.err == nil {
+		pub.err = fmt.Errorf("mqtt: %v", err)
+		pub.conn.Close()
+	}
+}
+
+// read discards the broker's ping responses until the connection fails.
+func (pub *Publisher) read(r *bufio.Reader) {
+	defer close(pub.done)
+	for {
+		if _, _, err := readPacket(r); err != nil {
+			pub.mu.Lock()
+			pub.fail(err)
+			pub.mu.Unlock()
+			return
+		}
+	}
+}
+
+// ping keeps the connection alive while no events are published.
+func (pub *Publisher) ping() {
+	t := time.NewTicker(pub.opts.KeepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if pub.write(pingreq, nil) != nil {
+				return
+			}
+		case <-pub.stop:
+			return
+		}
+	}
+}
+
+// Err returns the failure of the connection, if it has failed.
+func (pub *Publisher) Err() error {
+	pub.mu.Lock()
+	defer pub.mu.Unlock()
+	return pub.err
+}
+
+// Close disconnects from the broker, returning the failure of the
+// connection if it had failed.
+func (pub *Publisher) Close() error {
+	err := pub.write(disconnect, nil)
+	pub.mu.Lock()
+	if pub.err == ErrClosed {
+		pub.mu.Unlock()
+		return ErrClosed
+	}
+	pub.err = ErrClosed
+	pub.conn.Close()
+	pub.mu.Unlock()
+	close(pub.stop)
+	<-pub.done
+	return err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// readPacket returns the first byte and the body of the next packet.
+func readPacket(r *bufio.Reader) (kind byte, body []byte, err error) {
+	if kind, err = r.ReadByte(); err != nil {
+		return
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return
+	}
+	if n > 1<<28 {
+		return 0, nil, fmt.Errorf("packet length %d", n)
+	}
+	body = make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return
+}
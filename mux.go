@@ -0,0 +1,95 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Mux is a bus multiplexer, e.g. an I2C mux, whose channel is picked by
+// select pins. Users take turns: a channel stays selected for its user
+// until released.
+type Mux struct {
+	mu    sync.Mutex
+	sel   *Bus
+	codes []uint64
+	cur   int
+}
+
+// Most select pins of a mux.
+const maxMuxPins = 16
+
+// NewMux returns the mux of the select pins, least significant first, with
+// channel n selected by the pins reading n.
+func NewMux(pins ...*Pin) (*Mux, error) {
+	if len(pins) > maxMuxPins {
+		return nil, fmt.Errorf("mux of %d select pins", len(pins))
+	}
+	codes := make([]uint64, 1<<uint(len(pins)))
+	for i := range codes {
+		codes[i] = uint64(i)
+	}
+	return NewMuxTable(codes, pins...)
+}
+
+// NewMuxTable is NewMux with channel n selected by the pins reading
+// codes[n].
+func NewMuxTable(codes []uint64, pins ...*Pin) (*Mux, error) {
+	if len(pins) > maxMuxPins {
+		return nil, fmt.Errorf("mux of %d select pins", len(pins))
+	}
+	sel, err := NewBus(pins...)
+	if err != nil {
+		return nil, err
+	}
+	if err = sel.SetDirection("low"); err != nil {
+		sel.Close()
+		return nil, err
+	}
+	return &Mux{sel: sel, codes: append([]uint64(nil), codes...),
+		cur: -1}, nil
+}
+
+func (m *Mux) String() string { return "mux " + m.sel.String() }
+
+// Channels returns the number of channels.
+func (m *Mux) Channels() int { return len(m.codes) }
+
+// Select waits for the mux to be free and selects the channel, which stays
+// selected until release is called.
+func (m *Mux) Select(channel int) (release func(), err error) {
+	if channel < 0 || channel >= len(m.codes) {
+		return nil, fmt.Errorf("%s: no channel %d", m, channel)
+	}
+	m.mu.Lock()
+	if channel != m.cur {
+		if err = m.sel.Write(m.codes[channel]); err != nil {
+			m.cur = -1
+			m.mu.Unlock()
+			return nil, err
+		}
+		m.cur = channel
+	}
+	var once sync.Once
+	return func() { once.Do(m.mu.Unlock) }, nil
+}
+
+// Do runs f with the channel selected.
+func (m *Mux) Do(channel int, f func() error) error {
+	release, err := m.Select(channel)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return f()
+}
+
+// Close releases the select pins.
+func (m *Mux) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sel.Close()
+}
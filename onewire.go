@@ -0,0 +1,287 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"time"
+)
+
+// OneWire bit-bangs a 1-Wire bus on a pin with an external pull-up, run
+// open-drain like I2CMaster's lines, at the line's levels whether or not
+// the pin is ActiveLow.
+//
+// Its slots are microseconds wide, so it spins rather than sleeps and needs
+// a backend whose operations take a few microseconds at most, such as Mmap;
+// through sysfs the slots overrun and the devices mostly don't answer.
+// Errors of a garbled transfer show as CRC failures.
+type OneWire struct {
+	Pin *Pin
+}
+
+// 1-Wire ROM commands.
+const (
+	oneWireSearchROM = 0xf0
+	oneWireMatchROM  = 0x55
+	oneWireSkipROM   = 0xcc
+)
+
+// NewOneWire releases the line and checks that it's pulled high.
+func NewOneWire(p *Pin) (*OneWire, error) {
+	w := &OneWire{Pin: p}
+	if err := w.release(); err != nil {
+		return nil, err
+	}
+	if v, err := p.lineLevel(); err != nil {
+		return nil, err
+	} else if !v {
+		return nil, fmt.Errorf("%s: held low; missing pull-up?", p)
+	}
+	return w, nil
+}
+
+func (w *OneWire) release() error { return w.Pin.SetDirection("in") }
+func (w *OneWire) low() error     { return w.Pin.lineLow() }
+
+// spin waits d without giving up the thread, for slots too short to sleep.
+func spin(d time.Duration) {
+	for t := time.Now(); time.Since(t) < d; {
+	}
+}
+
+// Reset resets the bus and returns whether any device answered with a
+// presence pulse.
+func (w *OneWire) Reset() (present bool, err error) {
+	if err = w.low(); err != nil {
+		return
+	}
+	spin(480 * time.Microsecond)
+	if err = w.release(); err != nil {
+		return
+	}
+	spin(70 * time.Microsecond)
+	v, err := w.Pin.lineLevel()
+	spin(410 * time.Microsecond)
+	return !v, err
+}
+
+func (w *OneWire) writeBit(b bool) error {
+	low, high := 60*time.Microsecond, 10*time.Microsecond
+	if b {
+		low, high = 6*time.Microsecond, 64*time.Microsecond
+	}
+	if err := w.low(); err != nil {
+		return err
+	}
+	spin(low)
+	if err := w.release(); err != nil {
+		return err
+	}
+	spin(high)
+	return nil
+}
+
+func (w *OneWire) readBit() (b bool, err error) {
+	if err = w.low(); err != nil {
+		return
+	}
+	spin(6 * time.Microsecond)
+	if err = w.release(); err != nil {
+		return
+	}
+	spin(9 * time.Microsecond)
+	b, err = w.Pin.lineLevel()
+	spin(55 * time.Microsecond)
+	return
+}
+
+// WriteByte shifts out b, least significant bit first.
+func (w *OneWire) WriteByte(b byte) error {
+	for i := uint(0); i < 8; i++ {
+		if err := w.writeBit(b&(1<<i) != 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadByte shifts in a byte, least significant bit first.
+func (w *OneWire) ReadByte() (b byte, err error) {
+	for i := uint(0); i < 8; i++ {
+		bit, e := w.readBit()
+		if e != nil {
+			return 0, e
+		}
+		if bit {
+			b |= 1 << i
+		}
+	}
+	return
+}
+
+// Write shifts out data.
+func (w *OneWire) Write(data []byte) error {
+	for _, b := range data {
+		if err := w.WriteByte(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read shifts in n bytes.
+func (w *OneWire) Read(n int) (data []byte, err error) {
+	data = make([]byte, n)
+	for i := range data {
+		if data[i], err = w.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// Select resets the bus and addresses the device with the given ROM code
+// or, given 0, all of them, ready for a function command.
+func (w *OneWire) Select(rom uint64) error {
+	present, err := w.Reset()
+	if err != nil {
+		return err
+	}
+	if !present {
+		return fmt.Errorf("%s: no 1-wire device present", w.Pin)
+	}
+	if rom == 0 {
+		return w.WriteByte(oneWireSkipROM)
+	}
+	b := []byte{oneWireMatchROM, 0, 0, 0, 0, 0, 0, 0, 0}
+	for i := 0; i < 8; i++ {
+		b[1+i] = byte(rom >> uint(8*i))
+	}
+	return w.Write(b)
+}
+
+// Search returns the ROM codes of the devices on the bus, family code in
+// the low byte.
+func (w *OneWire) Search() (roms []uint64, err error) {
+	var rom uint64
+	last := -1
+	for {
+		present, e := w.Reset()
+		if e != nil {
+			return nil, e
+		}
+		if !present {
+			return
+		}
+		if err = w.WriteByte(oneWireSearchROM); err != nil {
+			return nil, err
+		}
+		fork := -1
+		for i := 0; i < 64; i++ {
+			b, e := w.readBit()
+			if e != nil {
+				return nil, e
+			}
+			nb, e := w.readBit()
+			if e != nil {
+				return nil, e
+			}
+			var dir bool
+			switch {
+			case b && nb:
+				return nil, fmt.Errorf("%s: 1-wire search lost its devices",
+					w.Pin)
+			case b != nb:
+				dir = b
+			case i < last:
+				dir = rom&(1<<uint(i)) != 0
+			default:
+				dir = i == last
+			}
+			if b == nb && !dir {
+				fork = i
+			}
+			if dir {
+				rom |= 1 << uint(i)
+			} else {
+				rom &^= 1 << uint(i)
+			}
+			if err = w.writeBit(dir); err != nil {
+				return nil, err
+			}
+		}
+		if !OneWireCRCValid(rom) {
+			return nil, fmt.Errorf("%s: 1-wire rom %016x: bad crc",
+				w.Pin, rom)
+		}
+		roms = append(roms, rom)
+		if last = fork; last < 0 {
+			return
+		}
+	}
+}
+
+// OneWireCRC returns the Dallas/Maxim CRC-8 of data.
+func OneWireCRC(data []byte) (crc byte) {
+	for _, b := range data {
+		for i := 0; i < 8; i++ {
+			mix := (crc ^ b) & 1
+			crc >>= 1
+			if mix != 0 {
+				crc ^= 0x8c
+			}
+			b >>= 1
+		}
+	}
+	return
+}
+
+// OneWireCRCValid reports whether the top byte of a ROM code is the CRC of
+// the others.
+func OneWireCRCValid(rom uint64) bool {
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = byte(rom >> uint(8*i))
+	}
+	return OneWireCRC(b[:7]) == b[7]
+}
+
+// ReadTemperature has a DS18B20, or all the bus's given 0, convert, waits
+// out the 12-bit conversion and returns its reading in degrees Celsius.
+func (w *OneWire) ReadTemperature(rom uint64) (float64, error) {
+	if err := w.Select(rom); err != nil {
+		return 0, err
+	}
+	if err := w.WriteByte(0x44); err != nil {
+		return 0, err
+	}
+	time.Sleep(750 * time.Millisecond)
+	if err := w.Select(rom); err != nil {
+		return 0, err
+	}
+	if err := w.WriteByte(0xbe); err != nil {
+		return 0, err
+	}
+	b, err := w.Read(9)
+	if err != nil {
+		return 0, err
+	}
+	if OneWireCRC(b[:8]) != b[8] {
+		return 0, fmt.Errorf("%s: ds18b20 %016x: bad scratchpad crc",
+			w.Pin, rom)
+	}
+	return float64(int16(uint16(b[0])|uint16(b[1])<<8)) / 16, nil
+}
+
+// ReadMemory reads n bytes from addr of a DS2431, or similar EEPROM.
+func (w *OneWire) ReadMemory(rom uint64, addr uint16, n int) ([]byte, error) {
+	if err := w.Select(rom); err != nil {
+		return nil, err
+	}
+	if err := w.Write([]byte{0xf0, byte(addr), byte(addr >> 8)}); err != nil {
+		return nil, err
+	}
+	return w.Read(n)
+}
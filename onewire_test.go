@@ -0,0 +1,34 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"testing"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+func TestOneWireActiveLow(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 3, Name: "w1", ActiveLow: true, Backend: be}
+	be.Inject(3, true)
+	w, err := gpio.NewOneWire(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	be.ClearOps()
+	if err = w.WriteByte(0); err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range be.Ops() {
+		if op.Arg != "high" && op.Arg != "in" {
+			t.Fatalf("ActiveLow line driven by %v", op)
+		}
+	}
+	if len(be.Ops()) != 16 {
+		t.Errorf("ops %v, want 8 slots", be.Ops())
+	}
+}
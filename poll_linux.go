@@ -0,0 +1,108 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"sync"
+	"syscall"
+	"time"
+)
+
+// poller waits for events on a set of file descriptors until stopped from
+// another goroutine.
+type poller struct {
+	epfd int
+	// Stop pipe; the read end is also in the epoll set.
+	pipe [2]int
+	ev   [32]syscall.EpollEvent
+	// Guards the descriptors against stop after close, which sets them
+	// to -1.
+	mu sync.Mutex
+}
+
+func newPoller() (pl *poller, err error) {
+	pl = &poller{epfd: -1, pipe: [2]int{-1, -1}}
+	defer func() {
+		if err != nil {
+			pl.close()
+			pl = nil
+		}
+	}()
+	if pl.epfd, err = syscall.EpollCreate1(syscall.EPOLL_CLOEXEC); err != nil {
+		return
+	}
+	err = syscall.Pipe2(pl.pipe[:], syscall.O_CLOEXEC|syscall.O_NONBLOCK)
+	if err != nil {
+		return
+	}
+	err = pl.add(pl.pipe[0], syscall.EPOLLIN)
+	return
+}
+
+func (pl *poller) add(fd int, events uint32) error {
+	return syscall.EpollCtl(pl.epfd, syscall.EPOLL_CTL_ADD, fd,
+		&syscall.EpollEvent{Events: events, Fd: int32(fd)})
+}
+
+func (pl *poller) remove(fd int) error {
+	return syscall.EpollCtl(pl.epfd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
+// wait blocks until descriptors have events and returns them, or returns
+// false once stop has been called. A timeout that isn't negative returns
+// no descriptors once it passes. Only one goroutine may wait.
+func (pl *poller) wait(timeout time.Duration) (fds []int, ok bool, err error) {
+	ms := -1
+	if timeout >= 0 {
+		ms = int((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	for {
+		n, err := syscall.EpollWait(pl.epfd, pl.ev[:], ms)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if n == 0 && ms >= 0 {
+			return nil, true, nil
+		}
+		for _, e := range pl.ev[:n] {
+			if int(e.Fd) == pl.pipe[0] {
+				return nil, false, nil
+			}
+			fds = append(fds, int(e.Fd))
+		}
+		if len(fds) > 0 {
+			return fds, true, nil
+		}
+	}
+}
+
+// stop has wait return false; it does nothing once the poller is closed.
+func (pl *poller) stop() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.pipe[1] >= 0 {
+		syscall.Write(pl.pipe[1], []byte{0})
+	}
+}
+
+func (pl *poller) close() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	for _, fd := range []*int{&pl.epfd, &pl.pipe[0], &pl.pipe[1]} {
+		if *fd >= 0 {
+			syscall.Close(*fd)
+			*fd = -1
+		}
+	}
+}
+
+// Events polled for on sysfs value attributes and on line requests.
+const (
+	pollSysfsEdge = syscall.EPOLLPRI | syscall.EPOLLERR
+	pollReadable  = syscall.EPOLLIN
+)
@@ -0,0 +1,30 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import (
+	"errors"
+	"time"
+)
+
+type poller struct{}
+
+const (
+	pollSysfsEdge = 0
+	pollReadable  = 0
+)
+
+func newPoller() (*poller, error) {
+	return nil, errors.New("gpio edge watching requires linux")
+}
+
+func (*poller) add(int, uint32) error                   { return nil }
+func (*poller) remove(int) error                        { return nil }
+func (*poller) wait(time.Duration) ([]int, bool, error) { return nil, false, nil }
+func (*poller) stop()                                   {}
+func (*poller) close()                                  {}
@@ -0,0 +1,91 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package promgpio is a Prometheus collector of the gpio pins: the value of
+// each, with its direction as a label, and the counters of gpio.Pin's
+// Counters, as gpio.WriteMetrics writes them, e.g.
+//
+//	err := promgpio.Register(prometheus.DefaultRegisterer, nil)
+//
+// to have presence and fault lines scraped with the rest of a process's
+// metrics. Pins are read at each scrape.
+package promgpio
+
+import (
+	"strconv"
+
+	"github.com/platinasystems/gpio"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	labels = []string{"name", "gpio"}
+
+	valueDesc = prometheus.NewDesc("gpio_value",
+		"Current logical value of the gpio.",
+		append(labels, "direction"), nil)
+	togglesDesc = prometheus.NewDesc("gpio_toggles_total",
+		"Writes changing the gpio's value.", labels, nil)
+	edgesDesc = prometheus.NewDesc("gpio_edges_total",
+		"Edges delivered to watches of the gpio.", labels, nil)
+	errorsDesc = prometheus.NewDesc("gpio_errors_total",
+		"Failed operations on the gpio.", labels, nil)
+)
+
+// Collector collects the metrics of the pins of the pin map.
+type Collector struct {
+	filter func(*gpio.Pin) bool
+}
+
+// NewCollector returns a collector of the pins that filter, if not nil,
+// accepts.
+func NewCollector(filter func(*gpio.Pin) bool) *Collector {
+	return &Collector{filter}
+}
+
+// Register registers a collector of the pins that filter, if not nil,
+// accepts.
+func Register(r prometheus.Registerer, filter func(*gpio.Pin) bool) error {
+	return r.Register(NewCollector(filter))
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{valueDesc, togglesDesc,
+		edgesDesc, errorsDesc} {
+		ch <- d
+	}
+}
+
+// Collect reads each pin; those whose value or direction can't be read are
+// left out of gpio_value.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, p := range gpio.SortedPins() {
+		if c.filter != nil && !c.filter(p) {
+			continue
+		}
+		name, num := p.Name, strconv.Itoa(p.Gpio)
+		if dir, err := p.Direction(); err == nil {
+			if v, err := p.Value(); err == nil {
+				x := 0.0
+				if v {
+					x = 1
+				}
+				ch <- prometheus.MustNewConstMetric(valueDesc,
+					prometheus.GaugeValue, x, name, num, dir)
+			}
+		}
+		n := p.Counters()
+		for _, m := range []struct {
+			d *prometheus.Desc
+			n uint64
+		}{
+			{togglesDesc, n.Toggles},
+			{edgesDesc, n.Edges},
+			{errorsDesc, n.Errors},
+		} {
+			ch <- prometheus.MustNewConstMetric(m.d,
+				prometheus.CounterValue, float64(m.n), name, num)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package promgpio
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollector(t *testing.T) {
+	be := gpiotest.New()
+	gpio.InitFromTree(nil, gpio.WithOffline())
+	led := &gpio.Pin{Gpio: 1, Name: "led", Backend: be}
+	present := &gpio.Pin{Gpio: 2, Name: "psu_present", Backend: be}
+	if err := gpio.RegisterPins(led, present); err != nil {
+		t.Fatal(err)
+	}
+	led.SetDirection("low")
+	led.Toggle()
+	led.Toggle()
+	c, err := present.Watch(gpio.EdgeBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer present.Watch(gpio.EdgeNone)
+	for _, v := range []bool{true, false, true} {
+		be.Inject(2, v)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %d of 3 edges", i)
+		}
+	}
+
+	r := prometheus.NewPedanticRegistry()
+	if err := Register(r, func(p *gpio.Pin) bool {
+		return strings.HasSuffix(p.Name, "_present") || p == led
+	}); err != nil {
+		t.Fatal(err)
+	}
+	mfs, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			var l []string
+			for _, lp := range m.GetLabel() {
+				l = append(l, lp.GetName()+"="+lp.GetValue())
+			}
+			v := m.GetCounter().GetValue() + m.GetGauge().GetValue()
+			got[mf.GetName()+"{"+strings.Join(l, ",")+"}"] = v
+		}
+	}
+	for k, v := range map[string]float64{
+		"gpio_value{direction=out,gpio=1,name=led}":        0,
+		"gpio_value{direction=in,gpio=2,name=psu_present}": 1,
+		"gpio_toggles_total{gpio=1,name=led}":              2,
+		"gpio_toggles_total{gpio=2,name=psu_present}":      0,
+		"gpio_edges_total{gpio=1,name=led}":                0,
+		"gpio_edges_total{gpio=2,name=psu_present}":        3,
+		"gpio_errors_total{gpio=2,name=psu_present}":       0,
+	} {
+		if x, f := got[k]; !f || x != v {
+			t.Errorf("%s = %v (%v), want %v", k, x, f, v)
+		}
+	}
+	if n := len(got); n != 8 {
+		t.Errorf("%d metrics, want 8: %v", n, got)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/fdt"
+)
+
+// Pwm is a channel of a kernel PWM chip, driven through /sys/class/pwm.
+type Pwm struct {
+	Name string
+	// The kernel's pwmchip, e.g. "pwmchip0".
+	Chip    string
+	Channel int
+	// Period and polarity set on export, from the device tree; zero and
+	// false leave the kernel's.
+	Period   time.Duration
+	Inverted bool
+	NodePath string
+
+	op sync.Mutex
+}
+
+// The device tree derived pwms by name.
+var pwms = make(map[string]*Pwm)
+
+// NewPwm returns the pwm of a chip's channel, to be exported before use.
+func NewPwm(name, chip string, channel int) *Pwm {
+	return &Pwm{Name: name, Chip: chip, Channel: channel}
+}
+
+// FindPwm looks a device tree derived pwm up by name.
+func FindPwm(name string) (pw *Pwm, f bool) {
+	gpioInit()
+	regMu.RLock()
+	defer regMu.RUnlock()
+	pw, f = pwms[name]
+	return
+}
+
+// SortedPwms returns the device tree derived pwms ordered by name.
+func SortedPwms() []*Pwm {
+	gpioInit()
+	regMu.RLock()
+	l := make([]*Pwm, 0, len(pwms))
+	for _, pw := range pwms {
+		l = append(l, pw)
+	}
+	regMu.RUnlock()
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+func (pw *Pwm) String() string { return pw.Name }
+
+func (pw *Pwm) chipDir() string {
+	return prefix + "/sys/class/pwm/" + pw.Chip
+}
+
+func (pw *Pwm) dir() string {
+	return fmt.Sprintf("%s/pwm%d", pw.chipDir(), pw.Channel)
+}
+
+func (pw *Pwm) fail(op string, err *error) {
+	if *err != nil {
+		*err = fmt.Errorf("%s: %s: %w", pw, op, *err)
+	}
+}
+
+func (pw *Pwm) write(fn string, format string, args ...interface{}) error {
+	f, err := os.OpenFile(fn, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, format, args...)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (pw *Pwm) readNs(name string) (time.Duration, error) {
+	b, err := ioutil.ReadFile(pw.dir() + "/" + name)
+	if err != nil {
+		return 0, err
+	}
+	ns, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	return time.Duration(ns), err
+}
+
+func (pw *Pwm) IsExported() bool {
+	_, err := os.Stat(pw.dir() + "/enable")
+	return err == nil
+}
+
+// Export exports the channel then sets its device tree polarity and period.
+func (pw *Pwm) Export() (err error) {
+	defer pw.fail("export", &err)
+	pw.op.Lock()
+	defer pw.op.Unlock()
+	if pw.Chip == "" {
+		return fmt.Errorf("no pwmchip")
+	}
+	if !pw.IsExported() {
+		err = pw.write(pw.chipDir()+"/export", "%d\n", pw.Channel)
+		if err != nil {
+			return
+		}
+	}
+	if pw.Inverted {
+		if err = pw.write(pw.dir()+"/polarity", "inversed\n"); err != nil {
+			return
+		}
+	}
+	if pw.Period > 0 {
+		err = pw.setPeriod(pw.Period)
+	}
+	return
+}
+
+func (pw *Pwm) Unexport() (err error) {
+	defer pw.fail("unexport", &err)
+	pw.op.Lock()
+	defer pw.op.Unlock()
+	return pw.write(pw.chipDir()+"/unexport", "%d\n", pw.Channel)
+}
+
+// SetPeriod sets the period, first shortening the duty cycle to it if
+// that's longer, as the kernel insists.
+func (pw *Pwm) SetPeriod(d time.Duration) (err error) {
+	defer pw.fail("set period", &err)
+	pw.op.Lock()
+	defer pw.op.Unlock()
+	return pw.setPeriod(d)
+}
+
+func (pw *Pwm) setPeriod(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("invalid period %v", d)
+	}
+	if duty, err := pw.readNs("duty_cycle"); err == nil && duty > d {
+		err = pw.write(pw.dir()+"/duty_cycle", "%d\n", d.Nanoseconds())
+		if err != nil {
+			return err
+		}
+	}
+	return pw.write(pw.dir()+"/period", "%d\n", d.Nanoseconds())
+}
+
+// SetDuty sets how long each period is active, at most the period.
+func (pw *Pwm) SetDuty(d time.Duration) (err error) {
+	defer pw.fail("set duty", &err)
+	pw.op.Lock()
+	defer pw.op.Unlock()
+	if d < 0 {
+		return fmt.Errorf("invalid duty %v", d)
+	}
+	return pw.write(pw.dir()+"/duty_cycle", "%d\n", d.Nanoseconds())
+}
+
+// SetDutyCycle sets the active fraction, 0 through 1, of the present
+// period.
+func (pw *Pwm) SetDutyCycle(duty float64) (err error) {
+	defer pw.fail("set duty cycle", &err)
+	pw.op.Lock()
+	defer pw.op.Unlock()
+	if duty < 0 || duty > 1 {
+		return fmt.Errorf("invalid duty cycle %g", duty)
+	}
+	period, err := pw.readNs("period")
+	if err != nil {
+		return
+	}
+	return pw.write(pw.dir()+"/duty_cycle", "%d\n",
+		int64(float64(period.Nanoseconds())*duty))
+}
+
+// SetPolarity sets whether the channel is active low; most chips only
+// take it while disabled.
+func (pw *Pwm) SetPolarity(inverted bool) (err error) {
+	defer pw.fail("set polarity", &err)
+	pw.op.Lock()
+	defer pw.op.Unlock()
+	s := "normal"
+	if inverted {
+		s = "inversed"
+	}
+	return pw.write(pw.dir()+"/polarity", "%s\n", s)
+}
+
+// Enable starts or stops the waveform.
+func (pw *Pwm) Enable(on bool) (err error) {
+	defer pw.fail("enable", &err)
+	pw.op.Lock()
+	defer pw.op.Unlock()
+	x := 0
+	if on {
+		x = 1
+	}
+	return pw.write(pw.dir()+"/enable", "%d\n", x)
+}
+
+// gatherPwms adds pwms for the pwms of a consumer node, e.g. a pwm-fan.
+// They go by the node's label, or failing that its name, suffixed by the
+// pwm-names entry or index when the node has more than one.
+func gatherPwms(n *fdt.Node, name string, value string) {
+	path := nodePaths[n]
+	base := strings.Split(n.Name, "@")[0]
+	if b, f := n.Properties["label"]; f && len(b) > 1 {
+		base = initTree.PropString(b)
+	}
+	var names []string
+	if b, f := n.Properties["pwm-names"]; f {
+		names = initTree.PropStringSlice(b)
+	}
+	cells := initTree.PropUint32Slice([]byte(value))
+	var found []*Pwm
+	for len(cells) != 0 {
+		ctl := phandleNode(cells[0])
+		if ctl == nil {
+			initError(fmt.Errorf("%s: pwms of no controller", path))
+			return
+		}
+		ncells := 2
+		if b, f := ctl.Properties["#pwm-cells"]; f && len(b) == 4 {
+			ncells = int(initTree.PropUint32(b))
+		}
+		if ncells == 0 || len(cells) < 1+ncells {
+			initError(fmt.Errorf("%s: malformed pwms", path))
+			return
+		}
+		pw := &Pwm{Channel: int(cells[1]), NodePath: path}
+		if ncells > 1 {
+			pw.Period = time.Duration(cells[2])
+		}
+		// Bit 0 of the flags cell is PWM_POLARITY_INVERTED.
+		pw.Inverted = ncells > 2 && cells[3]&1 != 0
+		if !initOffline {
+			pw.Chip = pwmChip(nodePaths[ctl])
+		}
+		found = append(found, pw)
+		cells = cells[1+ncells:]
+	}
+	regMu.Lock()
+	defer regMu.Unlock()
+	for i, pw := range found {
+		switch {
+		case len(found) == 1:
+			pw.Name = base
+		case i < len(names) && names[i] != "":
+			pw.Name = base + "." + names[i]
+		default:
+			pw.Name = fmt.Sprintf("%s.%d", base, i)
+		}
+		pwms[pw.Name] = pw
+	}
+}
+
+// phandleNode returns the node of the tree being gathered with the given
+// phandle.
+func phandleNode(ph uint32) *fdt.Node {
+	for n := range nodePaths {
+		for _, prop := range []string{"phandle", "linux,phandle"} {
+			if b, f := n.Properties[prop]; f && len(b) == 4 &&
+				initTree.PropUint32(b) == ph {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// pwmChip returns the kernel's pwmchip of a controller node, or "".
+func pwmChip(path string) string {
+	dirs, _ := filepath.Glob(prefix + "/sys/class/pwm/pwmchip*")
+	for _, dir := range dirs {
+		if ofNode(dir+"/device") == path {
+			return filepath.Base(dir)
+		}
+	}
+	return ""
+}
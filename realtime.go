@@ -0,0 +1,63 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// Whether the goroutines reading edges, of watches and EventMonitors,
+// lock their OS threads and raise their priority, guarded by mu.
+var (
+	eventReaderRealtime bool
+	eventReaderPriority func() error
+)
+
+// SetEventReaderRealtime selects whether the goroutines reading edges from
+// the kernel, of the watches and EventMonitors started from now on, each
+// lock their OS thread and raise its priority with the hook of
+// SetEventReaderPriority, so that fast signals are less likely to
+// overflow the kernel's event queue. It's a best-effort improvement of
+// latency, not a guarantee. The threads end with their goroutines, so
+// that no other goroutine runs at their priority.
+func SetEventReaderRealtime(on bool) {
+	mu.Lock()
+	eventReaderRealtime = on
+	mu.Unlock()
+}
+
+// SetEventReaderPriority sets the hook raising the priority of the calling
+// thread of a realtime event reader, e.g. SchedFIFO(1); nil, the default,
+// leaves it. Raising the priority usually takes privileges, e.g.
+// CAP_SYS_NICE or an RLIMIT_RTPRIO, so a failure is logged and the reader
+// goes on at its priority.
+func SetEventReaderPriority(raise func() error) {
+	mu.Lock()
+	eventReaderPriority = raise
+	mu.Unlock()
+}
+
+// realtimeReader makes the calling event reader goroutine realtime, if so
+// set.
+func realtimeReader() {
+	mu.Lock()
+	on, raise := eventReaderRealtime, eventReaderPriority
+	mu.Unlock()
+	if !on {
+		return
+	}
+	runtime.LockOSThread()
+	if raise == nil {
+		return
+	}
+	if err := raise(); err != nil {
+		if l := getLogger(); l != nil {
+			l.Log(context.Background(), slog.LevelWarn,
+				"gpio event reader priority", "err", err)
+		}
+	}
+}
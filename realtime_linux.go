@@ -0,0 +1,28 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const schedFIFO = 1
+
+// SchedFIFO returns a hook for SetEventReaderPriority that makes the
+// calling thread SCHED_FIFO at the given priority, 1 to 99.
+func SchedFIFO(priority int) func() error {
+	return func() error {
+		param := struct{ priority int32 }{int32(priority)}
+		_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER,
+			0, schedFIFO, uintptr(unsafe.Pointer(&param)))
+		if errno != 0 {
+			return fmt.Errorf("sched_setscheduler FIFO %d: %w",
+				priority, errno)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import "errors"
+
+// SchedFIFO returns a hook for SetEventReaderPriority, which fails but for
+// linux.
+func SchedFIFO(priority int) func() error {
+	return func() error {
+		return errors.New("gpio SCHED_FIFO requires linux")
+	}
+}
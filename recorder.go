@@ -0,0 +1,144 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Recorder is a logic analyzer of a set of pins, capturing their changes by
+// Sample or Capture for a Value Change Dump, e.g.
+//
+//	r := NewRecorder(enable, powerGood, reset)
+//	ctx, cancel := context.WithTimeout(ctx, time.Second)
+//	defer cancel()
+//	go r.Capture(ctx)
+//	sequencer.Run(ctx)
+//	r.WriteVCD(f)
+//
+// for viewing the power sequence in GTKWave.
+type Recorder struct {
+	pins []*Pin
+
+	mu      sync.Mutex
+	start   time.Time
+	initial []bool
+	changes []vcdChange
+}
+
+func NewRecorder(pins ...*Pin) *Recorder {
+	return &Recorder{pins: pins}
+}
+
+// begin reads the pins' levels at the start of a recording, discarding any
+// before.
+func (r *Recorder) begin() (levels []bool, err error) {
+	levels = make([]bool, len(r.pins))
+	for i, p := range r.pins {
+		if levels[i], err = p.Value(); err != nil {
+			return
+		}
+	}
+	r.mu.Lock()
+	r.start = time.Now()
+	r.initial = append([]bool(nil), levels...)
+	r.changes = nil
+	r.mu.Unlock()
+	return
+}
+
+func (r *Recorder) add(i int, t time.Time, v bool) {
+	r.mu.Lock()
+	r.changes = append(r.changes, vcdChange{i, t, v})
+	r.mu.Unlock()
+}
+
+// Sample records the pins by polling them at rate Hz until ctx is done,
+// with Trace's limits.
+func (r *Recorder) Sample(ctx context.Context, rate float64) error {
+	if rate <= 0 {
+		return fmt.Errorf("invalid sample rate %g", rate)
+	}
+	levels, err := r.begin()
+	if err != nil {
+		return err
+	}
+	t := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+		now := time.Now()
+		for i, p := range r.pins {
+			v, err := p.Value()
+			if err != nil {
+				return err
+			}
+			if v != levels[i] {
+				r.add(i, now, v)
+				levels[i] = v
+			}
+		}
+	}
+}
+
+// Capture records the edges of the pins, which must be inputs, with an
+// EventMonitor until ctx is done, replacing any watches of them.
+func (r *Recorder) Capture(ctx context.Context) error {
+	m, err := NewEventMonitor()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	index := make(map[*Pin]int, len(r.pins))
+	for i, p := range r.pins {
+		index[p] = i
+	}
+	if _, err = r.begin(); err != nil {
+		return err
+	}
+	for _, p := range r.pins {
+		if err = m.Add(p, EdgeBoth); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-m.Events():
+			if !ok {
+				return nil
+			}
+			r.add(index[e.Pin], e.Time, e.Value)
+		}
+	}
+}
+
+// WriteVCD writes the last recording as a Value Change Dump of a signal per
+// pin, by name, with nanosecond timescale relative to its start.
+func (r *Recorder) WriteVCD(w io.Writer) error {
+	names := make([]string, len(r.pins))
+	for i, p := range r.pins {
+		names[i] = p.Name
+	}
+	r.mu.Lock()
+	initial := r.initial
+	changes := append([]vcdChange(nil), r.changes...)
+	start := r.start
+	r.mu.Unlock()
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].t.Before(changes[j].t)
+	})
+	return writeVCD(w, names, initial, start, changes)
+}
@@ -0,0 +1,198 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package remote
+
+import (
+	"context"
+	"sync"
+
+	"github.com/platinasystems/gpio"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Line is what Pins of servers share with local *gpio.Pins, for code that
+// drives either.
+type Line interface {
+	Value() (bool, error)
+	SetValue(v bool) error
+	Direction() (string, error)
+	SetDirection(dir string) error
+}
+
+var (
+	_ Line = (*gpio.Pin)(nil)
+	_ Line = (*Pin)(nil)
+)
+
+// Client is a connection to a Server.
+type Client struct {
+	cc *grpc.ClientConn
+}
+
+// Dial returns a client of the server at target, e.g. "linecard3:7070",
+// which is connected to as needed. The connection is insecure unless the
+// options give credentials.
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	}, opts...)
+	cc, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cc}, nil
+}
+
+func (c *Client) Close() error { return c.cc.Close() }
+
+func (c *Client) call(method string, args, reply interface{}) error {
+	err := c.cc.Invoke(context.Background(),
+		"/"+serviceName+"/"+method, args, reply)
+	return errorOf(err)
+}
+
+// remoteError is the error of a server, matching the sentinel error of its
+// status code.
+type remoteError struct {
+	msg string
+	err error
+}
+
+func (e *remoteError) Error() string { return e.msg }
+
+func (e *remoteError) Is(target error) bool { return target == e.err }
+
+// errorOf gives the sentinel error of a status code back to the error.
+func errorOf(err error) error {
+	s, ok := status.FromError(err)
+	if err == nil || !ok {
+		return err
+	}
+	for _, x := range codeErrors {
+		if s.Code() == x.code {
+			return &remoteError{s.Message(), x.err}
+		}
+	}
+	return err
+}
+
+// Pins lists the server's pins in name order.
+func (c *Client) Pins() ([]PinInfo, error) {
+	var reply ListReply
+	err := c.call("List", &ListArgs{}, &reply)
+	return reply.Pins, err
+}
+
+// Pin returns the server's pin of the given name; it isn't looked up until
+// used.
+func (c *Client) Pin(name string) *Pin {
+	return &Pin{Name: name, c: c}
+}
+
+// Pin is a pin of a server.
+type Pin struct {
+	Name string
+
+	c  *Client
+	mu sync.Mutex
+	w  *remoteWatch
+}
+
+// Events buffered by a watch.
+const watchDepth = 64
+
+type remoteWatch struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (p *Pin) String() string { return p.Name }
+
+func (p *Pin) Value() (bool, error) {
+	var reply ValueReply
+	err := p.c.call("Get", &PinArgs{p.Name}, &reply)
+	return reply.Value, err
+}
+
+func (p *Pin) SetValue(v bool) error {
+	return p.c.call("Set", &ValueArgs{p.Name, v}, &Empty{})
+}
+
+func (p *Pin) Direction() (string, error) {
+	var reply DirectionReply
+	err := p.c.call("Direction", &PinArgs{p.Name}, &reply)
+	return reply.Direction, err
+}
+
+func (p *Pin) SetDirection(dir string) error {
+	return p.c.call("SetDirection", &DirectionArgs{p.Name, dir}, &Empty{})
+}
+
+// Watch delivers the pin's edges as gpio.Pin.Watch does, streamed by the
+// server; Watch(gpio.EdgeNone) stops watching and closes the channel, as
+// does the stream ending, e.g. by the connection failing or another
+// replacing the watch.
+func (p *Pin) Watch(edge gpio.Edge) (<-chan gpio.EdgeEvent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if w := p.w; w != nil {
+		p.w = nil
+		w.cancel()
+		<-w.done
+	}
+	if edge == gpio.EdgeNone {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := p.c.cc.NewStream(ctx, &serviceDesc.Streams[0],
+		"/"+serviceName+"/Watch")
+	if err == nil {
+		err = stream.SendMsg(&WatchArgs{p.Name, edge})
+	}
+	if err == nil {
+		err = stream.CloseSend()
+	}
+	if err == nil {
+		// The server sends a header once watching; without one the
+		// stream has ended with its error.
+		var md map[string][]string
+		if md, err = stream.Header(); err == nil && md == nil {
+			if err = stream.RecvMsg(&gpio.EdgeEvent{}); err == nil {
+				err = status.Error(codes.Internal,
+					"watch stream without header")
+			}
+		}
+	}
+	if err != nil {
+		cancel()
+		return nil, errorOf(err)
+	}
+	w := &remoteWatch{cancel: cancel, done: make(chan struct{})}
+	p.w = w
+	c := make(chan gpio.EdgeEvent, watchDepth)
+	go p.recv(ctx, stream, w, c)
+	return c, nil
+}
+
+func (p *Pin) recv(ctx context.Context, stream grpc.ClientStream,
+	w *remoteWatch, c chan gpio.EdgeEvent) {
+	defer close(w.done)
+	defer close(c)
+	for {
+		var e gpio.EdgeEvent
+		if stream.RecvMsg(&e) != nil {
+			return
+		}
+		select {
+		case c <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package remote
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// Content-subtype of the GPIO service's messages, of its own so as not to
+// replace the codec of another package's "json".
+const codecName = "gpio-json"
+
+// jsonCodec is the gRPC codec of the service's messages.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+func (jsonCodec) Name() string { return codecName }
+
+// Registered for servers of the caller's with the service, which pick the
+// codec by the content-subtype; the Client and NewServer force it.
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,173 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package remote
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+	"google.golang.org/grpc/encoding"
+)
+
+// unwatchBackend counts the watches stopped of a gpiotest backend.
+type unwatchBackend struct {
+	*gpiotest.Backend
+	mu      sync.Mutex
+	stopped int
+}
+
+func (b *unwatchBackend) Watch(p *gpio.Pin, edge gpio.Edge) (<-chan gpio.Event, error) {
+	if edge == gpio.EdgeNone {
+		b.mu.Lock()
+		b.stopped++
+		b.mu.Unlock()
+	}
+	return b.Backend.Watch(p, edge)
+}
+
+func (b *unwatchBackend) unwatched() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stopped
+}
+
+// serve registers pins led, an output, and button, an input, and returns a
+// client of a server of them.
+func serve(t *testing.T) (*Client, *unwatchBackend) {
+	be := &unwatchBackend{Backend: gpiotest.New()}
+	gpio.InitFromTree(nil, gpio.WithOffline())
+	led := &gpio.Pin{Gpio: 1, Name: "led", Backend: be}
+	button := &gpio.Pin{Gpio: 2, Name: "button", Backend: be}
+	if err := gpio.RegisterPins(led, button); err != nil {
+		t.Fatal(err)
+	}
+	if err := led.SetDirection("low"); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, be
+}
+
+func TestPin(t *testing.T) {
+	c, be := serve(t)
+	led := c.Pin("led")
+	if err := led.SetValue(true); err != nil {
+		t.Fatal(err)
+	}
+	if !be.Level(1) {
+		t.Error("led low after SetValue(true)")
+	}
+	if v, err := led.Value(); err != nil || !v {
+		t.Errorf("value %v, %v", v, err)
+	}
+	if dir, err := led.Direction(); err != nil || dir != "out" {
+		t.Errorf("direction %q, %v", dir, err)
+	}
+	if err := led.SetDirection("in"); err != nil {
+		t.Fatal(err)
+	}
+	if err := led.SetDirection("sideways"); err == nil {
+		t.Error("invalid direction didn't fail")
+	}
+}
+
+func TestPins(t *testing.T) {
+	c, _ := serve(t)
+	l, err := c.Pins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 || l[0].Name != "button" || l[1].Name != "led" ||
+		l[1].Direction != "out" || l[1].Gpio != 1 {
+		t.Errorf("pins %+v", l)
+	}
+}
+
+func TestNoSuchPin(t *testing.T) {
+	c, _ := serve(t)
+	if _, err := c.Pin("fan").Value(); !errors.Is(err, gpio.ErrNoSuchPin) {
+		t.Errorf("value of no pin: %v", err)
+	}
+	_, err := c.Pin("fan").Watch(gpio.EdgeBoth)
+	if !errors.Is(err, gpio.ErrNoSuchPin) {
+		t.Errorf("watch of no pin: %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c, be := serve(t)
+	button := c.Pin("button")
+	w, err := button.Watch(gpio.EdgeBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	be.Inject(2, true)
+	be.Inject(2, false)
+	for _, want := range []bool{true, false} {
+		select {
+		case e, ok := <-w:
+			if !ok {
+				t.Fatal("watch closed")
+			}
+			if e.Value != want || e.Rising != want {
+				t.Errorf("event %+v, want value %v", e, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+	}
+	if _, err = button.Watch(gpio.EdgeNone); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-w; ok {
+		t.Error("watch not closed by Watch(EdgeNone)")
+	}
+	waitUnwatched(t, be, 1)
+}
+
+func TestWatchDisconnect(t *testing.T) {
+	c, be := serve(t)
+	if _, err := c.Pin("button").Watch(gpio.EdgeRising); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	waitUnwatched(t, be, 1)
+}
+
+// waitUnwatched waits for the server to have stopped n watches.
+func waitUnwatched(t *testing.T, be *unwatchBackend, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); be.unwatched() < n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d watches stopped", be.unwatched(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	if _, f := encoding.GetCodec(codecName).(jsonCodec); !f {
+		t.Errorf("%s codec not registered", codecName)
+	}
+	if _, f := encoding.GetCodec("json").(jsonCodec); f {
+		t.Error("json codec replaced")
+	}
+}
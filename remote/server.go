@@ -0,0 +1,272 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package remote serves the gpio pin registry to other machines over gRPC,
+// e.g. a chassis controller driving the pins of its line cards, and is the
+// client of such servers, whose Pins work like local ones.
+//
+//	go remote.NewServer().Serve(l)
+//
+//	c, err := remote.Dial("linecard3:7070")
+//	err = c.Pin("sfp_reset").SetValue(true)
+//
+// The service, gpio.GPIO, has the unary methods List, Get, Set, Direction
+// and SetDirection and the server streaming Watch. Its messages are the
+// types of this package in JSON, with the "gpio-json" codec of content-type
+// application/grpc+gpio-json, rather than protobuf, so there's no .proto to
+// generate code from; clients in other languages register a JSON codec of
+// that name.
+// The package's sentinel errors are sent as status codes, NotFound for
+// gpio.ErrNoSuchPin and so on, and the Client's errors match them again.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/platinasystems/gpio"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PinInfo is a pin as listed by the server, with its state as read then.
+type PinInfo struct {
+	Name      string
+	Gpio      int
+	Direction string
+	Value     bool
+	Default   string
+	ActiveLow bool
+	// Why the state couldn't be read, if it couldn't.
+	Err string `json:",omitempty"`
+}
+
+// Requests and replies of the GPIO service methods; Watch streams
+// gpio.EdgeEvents.
+type (
+	ListArgs  struct{}
+	ListReply struct{ Pins []PinInfo }
+	PinArgs   struct{ Name string }
+	ValueArgs struct {
+		Name  string
+		Value bool
+	}
+	ValueReply     struct{ Value bool }
+	DirectionArgs  struct{ Name, Direction string }
+	DirectionReply struct{ Direction string }
+	WatchArgs      struct {
+		Name string
+		Edge gpio.Edge
+	}
+	Empty struct{}
+)
+
+// Server serves the pins FindPin finds.
+type Server struct {
+	g *grpc.Server
+}
+
+// NewServer returns a gRPC server, of the given options, e.g. credentials,
+// of just the GPIO service.
+func NewServer(opts ...grpc.ServerOption) *Server {
+	opts = append([]grpc.ServerOption{grpc.ForceServerCodec(jsonCodec{})},
+		opts...)
+	g := grpc.NewServer(opts...)
+	Register(g)
+	return &Server{g}
+}
+
+// Register adds the GPIO service to a gRPC server of the caller's.
+func Register(r grpc.ServiceRegistrar) {
+	r.RegisterService(&serviceDesc, nil)
+}
+
+// Serve serves the connections of l until it fails or Stop.
+func (s *Server) Serve(l net.Listener) error { return s.g.Serve(l) }
+
+// Stop closes the listeners and connections, ending their watches.
+func (s *Server) Stop() { s.g.Stop() }
+
+const serviceName = "gpio.GPIO"
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("List", func() interface{} { return new(ListArgs) }, list),
+		unary("Get", func() interface{} { return new(PinArgs) }, get),
+		unary("Set", func() interface{} { return new(ValueArgs) }, set),
+		unary("Direction", func() interface{} { return new(PinArgs) },
+			direction),
+		unary("SetDirection",
+			func() interface{} { return new(DirectionArgs) },
+			setDirection),
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Watch", Handler: watch, ServerStreams: true},
+	},
+}
+
+// unary describes the method of the given name, which call serves with the
+// request decoded into that of newArgs.
+func unary(name string, newArgs func() interface{},
+	call func(args interface{}) (interface{}, error)) grpc.MethodDesc {
+	h := func(ctx context.Context, args interface{}) (interface{}, error) {
+		reply, err := call(args)
+		return reply, statusOf(err)
+	}
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context,
+			dec func(interface{}) error,
+			ic grpc.UnaryServerInterceptor) (interface{}, error) {
+			args := newArgs()
+			if err := dec(args); err != nil {
+				return nil, err
+			}
+			if ic == nil {
+				return h(ctx, args)
+			}
+			return ic(ctx, args, &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: "/" + serviceName + "/" + name,
+			}, h)
+		},
+	}
+}
+
+// Status codes of the package's sentinel errors, both ways.
+var codeErrors = []struct {
+	code codes.Code
+	err  error
+}{
+	{codes.NotFound, gpio.ErrNoSuchPin},
+	{codes.Aborted, gpio.ErrBusy},
+	{codes.FailedPrecondition, gpio.ErrNotExported},
+	{codes.PermissionDenied, gpio.ErrPermission},
+	{codes.InvalidArgument, gpio.ErrBadDirection},
+}
+
+// statusOf gives an error the status code of the sentinel it matches, if
+// any.
+func statusOf(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, x := range codeErrors {
+		if errors.Is(err, x.err) {
+			return status.Error(x.code, err.Error())
+		}
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func findPin(name string) (*gpio.Pin, error) {
+	p, f := gpio.FindPin(name)
+	if !f {
+		return nil, fmt.Errorf("%s: %w", name, gpio.ErrNoSuchPin)
+	}
+	return p, nil
+}
+
+func list(args interface{}) (interface{}, error) {
+	reply := new(ListReply)
+	for _, p := range gpio.SortedPins() {
+		i := PinInfo{Name: p.Name, Gpio: p.Gpio, Default: p.Default,
+			ActiveLow: p.ActiveLow}
+		var err error
+		if i.Direction, err = p.Direction(); err == nil {
+			i.Value, err = p.Value()
+		}
+		if err != nil {
+			i.Err = err.Error()
+		}
+		reply.Pins = append(reply.Pins, i)
+	}
+	return reply, nil
+}
+
+func get(args interface{}) (interface{}, error) {
+	p, err := findPin(args.(*PinArgs).Name)
+	if err != nil {
+		return nil, err
+	}
+	reply := new(ValueReply)
+	reply.Value, err = p.Value()
+	return reply, err
+}
+
+func set(args interface{}) (interface{}, error) {
+	a := args.(*ValueArgs)
+	p, err := findPin(a.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &Empty{}, p.SetValue(a.Value)
+}
+
+func direction(args interface{}) (interface{}, error) {
+	p, err := findPin(args.(*PinArgs).Name)
+	if err != nil {
+		return nil, err
+	}
+	reply := new(DirectionReply)
+	reply.Direction, err = p.Direction()
+	return reply, err
+}
+
+func setDirection(args interface{}) (interface{}, error) {
+	a := args.(*DirectionArgs)
+	p, err := findPin(a.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &Empty{}, p.SetDirection(a.Direction)
+}
+
+// watch replaces the pin's watch, local or remote, with one streaming its
+// edges to the client until the stream ends, as by the client going away,
+// when it stops watching; a header is sent once watching, so the client
+// knows it's started. The stream also ends if the watch is replaced or
+// fails.
+func watch(srv interface{}, stream grpc.ServerStream) error {
+	var args WatchArgs
+	if err := stream.RecvMsg(&args); err != nil {
+		return err
+	}
+	p, err := findPin(args.Name)
+	if err != nil {
+		return statusOf(err)
+	}
+	if args.Edge == gpio.EdgeNone {
+		return status.Errorf(codes.InvalidArgument,
+			"%s: watch of no edge", args.Name)
+	}
+	c, err := p.Watch(args.Edge)
+	if err != nil {
+		return statusOf(err)
+	}
+	if err = stream.SendHeader(nil); err != nil {
+		p.Watch(gpio.EdgeNone)
+		return err
+	}
+	for {
+		select {
+		case e, ok := <-c:
+			if !ok {
+				return nil
+			}
+			if err = stream.SendMsg(&e.EdgeEvent); err != nil {
+				p.Watch(gpio.EdgeNone)
+				return err
+			}
+		case <-stream.Context().Done():
+			p.Watch(gpio.EdgeNone)
+			return stream.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"sync"
+	"time"
+)
+
+// Reset is a device's reset line. Asserted is the pin's logical high, so an
+// active-low reset wants an ActiveLow pin, or Inverted where the device
+// tree doesn't say so.
+type Reset struct {
+	Pin      *Pin
+	Inverted bool
+	// Shortest assertion the device takes, and how long it needs after
+	// deassertion before it's usable.
+	MinHold, Recovery time.Duration
+
+	mu       sync.Mutex
+	asserted time.Time
+}
+
+func (r *Reset) level(asserted bool) bool { return asserted != r.Inverted }
+
+// Assert puts the device in reset.
+func (r *Reset) Assert() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.assert()
+}
+
+func (r *Reset) assert() error {
+	dir := "low"
+	if r.level(true) {
+		dir = "high"
+	}
+	if err := r.Pin.SetDirection(dir); err != nil {
+		return err
+	}
+	if r.asserted.IsZero() {
+		r.asserted = time.Now()
+	}
+	return nil
+}
+
+// Deassert takes the device out of reset, once it's been in for MinHold,
+// and returns after Recovery.
+func (r *Reset) Deassert() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.deassert()
+}
+
+func (r *Reset) deassert() error {
+	if !r.asserted.IsZero() {
+		time.Sleep(r.MinHold - time.Since(r.asserted))
+	}
+	if err := r.Pin.SetValue(r.level(false)); err != nil {
+		return err
+	}
+	r.asserted = time.Time{}
+	time.Sleep(r.Recovery)
+	return nil
+}
+
+// AssertFor resets the device for d, or MinHold if that's longer, and
+// returns after Recovery.
+func (r *Reset) AssertFor(d time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.assert(); err != nil {
+		return err
+	}
+	time.Sleep(d - time.Since(r.asserted))
+	return r.deassert()
+}
+
+// IsAsserted reports whether Assert last left the device in reset.
+func (r *Reset) IsAsserted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.asserted.IsZero()
+}
@@ -0,0 +1,157 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SeqAction is what a sequence step does.
+type SeqAction int
+
+const (
+	// Drive Pin to Value.
+	SeqSet SeqAction = iota
+	// Wait Duration.
+	SeqWait
+	// Wait up to Duration for input Pin to read Value, failing if it
+	// doesn't.
+	SeqExpect
+)
+
+// SeqStep is a step of a power sequence, e.g.
+//
+//	{Name: "enable 12V", Action: SeqSet, Pin: en12v, Value: true}
+//	{Name: "settle", Action: SeqWait, Duration: 10 * time.Millisecond}
+//	{Name: "12V good", Action: SeqExpect, Pin: pg12v, Value: true,
+//		Duration: 100 * time.Millisecond}
+type SeqStep struct {
+	Name     string
+	Action   SeqAction
+	Pin      *Pin
+	Value    bool
+	Duration time.Duration
+}
+
+func (s *SeqStep) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	switch s.Action {
+	case SeqSet:
+		return fmt.Sprintf("set %s %v", s.Pin.Name, s.Value)
+	case SeqWait:
+		return fmt.Sprintf("wait %v", s.Duration)
+	case SeqExpect:
+		return fmt.Sprintf("expect %s %v", s.Pin.Name, s.Value)
+	}
+	return fmt.Sprintf("SeqAction(%d)", int(s.Action))
+}
+
+// Sequencer runs power sequence steps in order. On a failure the pins set
+// so far are driven back to the opposite of their step's value, last first.
+type Sequencer struct {
+	Steps []SeqStep
+	// How often SeqExpect reads its pin; 0 for every millisecond.
+	PollInterval time.Duration
+}
+
+// SequenceError reports the step a sequence failed at and how the
+// rollback went.
+type SequenceError struct {
+	Step int
+	Name string
+	Err  error
+	// The rollback's failures, if any.
+	RollbackErr error
+}
+
+func (e *SequenceError) Error() string {
+	s := fmt.Sprintf("sequence step %d (%s): %v", e.Step, e.Name, e.Err)
+	if e.RollbackErr != nil {
+		s += fmt.Sprintf("; rollback: %v", e.RollbackErr)
+	}
+	return s
+}
+
+func (e *SequenceError) Unwrap() error { return e.Err }
+
+// Run runs the steps until one fails or ctx is done, returning a
+// SequenceError after rolling back.
+func (seq *Sequencer) Run(ctx context.Context) error {
+	for i := range seq.Steps {
+		s := &seq.Steps[i]
+		if err := seq.step(ctx, s); err != nil {
+			return &SequenceError{Step: i, Name: s.String(), Err: err,
+				RollbackErr: seq.rollback(i)}
+		}
+	}
+	return nil
+}
+
+func (seq *Sequencer) step(ctx context.Context, s *SeqStep) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch s.Action {
+	case SeqSet:
+		dir := "low"
+		if s.Value {
+			dir = "high"
+		}
+		return s.Pin.SetDirection(dir)
+	case SeqWait:
+		return sleepCtx(ctx, s.Duration)
+	case SeqExpect:
+		poll := seq.PollInterval
+		if poll <= 0 {
+			poll = time.Millisecond
+		}
+		deadline := time.Now().Add(s.Duration)
+		for {
+			v, err := s.Pin.Value()
+			if err != nil {
+				return err
+			}
+			if v == s.Value {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s not %v after %v", s.Pin.Name,
+					s.Value, s.Duration)
+			}
+			if err = sleepCtx(ctx, poll); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("invalid action %d", int(s.Action))
+}
+
+// rollback undoes the set steps before step n, last first.
+func (seq *Sequencer) rollback(n int) error {
+	var errs errorList
+	for i := n - 1; i >= 0; i-- {
+		if s := &seq.Steps[i]; s.Action == SeqSet {
+			if err := s.Pin.SetValue(!s.Value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs.err()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+// powerSequence returns the sequence of enabling two rails, the second
+// checked by its power good input.
+func powerSequence(be *gpiotest.Backend) *gpio.Sequencer {
+	en5v := &gpio.Pin{Gpio: 1, Name: "en_5v", Backend: be}
+	en12v := &gpio.Pin{Gpio: 2, Name: "en_12v", Backend: be}
+	pg12v := &gpio.Pin{Gpio: 3, Name: "pg_12v", Backend: be}
+	return &gpio.Sequencer{Steps: []gpio.SeqStep{
+		{Action: gpio.SeqSet, Pin: en5v, Value: true},
+		{Action: gpio.SeqWait, Duration: time.Millisecond},
+		{Action: gpio.SeqSet, Pin: en12v, Value: true},
+		{Name: "12V good", Action: gpio.SeqExpect, Pin: pg12v, Value: true,
+			Duration: 20 * time.Millisecond},
+	}}
+}
+
+func TestSequencer(t *testing.T) {
+	be := gpiotest.New()
+	seq := powerSequence(be)
+	be.Inject(3, true)
+	if err := seq.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []gpiotest.Op{
+		{Gpio: 1, Kind: "direction", Arg: "high"},
+		{Gpio: 2, Kind: "direction", Arg: "high"},
+	}
+	if ops := be.Ops(); !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops %v, want %v", ops, want)
+	}
+}
+
+func TestSequencerRollback(t *testing.T) {
+	be := gpiotest.New()
+	seq := powerSequence(be)
+	err := seq.Run(context.Background())
+	var se *gpio.SequenceError
+	if !errors.As(err, &se) || se.Step != 3 || se.Name != "12V good" ||
+		se.RollbackErr != nil {
+		t.Fatalf("err %v, want a failure of step 3 rolled back", err)
+	}
+	want := []gpiotest.Op{
+		{Gpio: 1, Kind: "direction", Arg: "high"},
+		{Gpio: 2, Kind: "direction", Arg: "high"},
+		{Gpio: 2, Kind: "value", Arg: "0"},
+		{Gpio: 1, Kind: "value", Arg: "0"},
+	}
+	if ops := be.Ops(); !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops %v, want %v", ops, want)
+	}
+}
+
+func TestSequencerAbort(t *testing.T) {
+	be := gpiotest.New()
+	seq := powerSequence(be)
+	seq.Steps[1].Duration = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	err := seq.Run(ctx)
+	var se *gpio.SequenceError
+	if !errors.As(err, &se) || se.Step != 1 ||
+		!errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err %v, want step 1 cut short", err)
+	}
+	if be.Level(1) || be.Level(2) {
+		t.Error("rails left enabled")
+	}
+}
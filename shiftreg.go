@@ -0,0 +1,193 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ShiftRegister drives a chain of 74HC595 shift registers from data, clock
+// and latch pins, and is the Backend of the chain's outputs, Pins. Pin i is
+// output Qi%8 of chip i/8, chip 0 being the one wired to the data pin.
+//
+// Every write shifts the whole chain out then pulses the latch, so the
+// outputs change together; a PinGroup of the Pins writes them all with a
+// single latch. Reads return the value last latched and there are no
+// inputs or edges.
+type ShiftRegister struct {
+	Data, Clock, Latch *Pin
+	Pins               []*Pin
+
+	mu   sync.Mutex
+	bits []bool
+}
+
+// NewShiftRegister returns the backend of n chained chips, naming their
+// outputs by prefix and index, e.g. "led0", and latches them all low.
+func NewShiftRegister(data, clock, latch *Pin, n int, prefix string) (*ShiftRegister, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("shift register of %d chips", n)
+	}
+	sr := &ShiftRegister{Data: data, Clock: clock, Latch: latch,
+		Pins: make([]*Pin, 8*n), bits: make([]bool, 8*n)}
+	for i := range sr.Pins {
+		sr.Pins[i] = &Pin{Gpio: i, Name: fmt.Sprintf("%s%d", prefix, i),
+			Backend: sr}
+	}
+	for _, p := range []*Pin{data, clock, latch} {
+		if err := p.SetDirection("low"); err != nil {
+			return nil, err
+		}
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if err := sr.shift(); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+// shift clocks the bits out, last first, and latches them.
+func (sr *ShiftRegister) shift() error {
+	for i := len(sr.bits) - 1; i >= 0; i-- {
+		if err := sr.Data.SetValue(sr.bits[i]); err != nil {
+			return err
+		}
+		if err := sr.Clock.SetValue(true); err != nil {
+			return err
+		}
+		if err := sr.Clock.SetValue(false); err != nil {
+			return err
+		}
+	}
+	if err := sr.Latch.SetValue(true); err != nil {
+		return err
+	}
+	return sr.Latch.SetValue(false)
+}
+
+func (sr *ShiftRegister) line(p *Pin) (int, error) {
+	if p.Gpio < 0 || p.Gpio >= len(sr.bits) {
+		return 0, fmt.Errorf("no output %d of a %d bit shift register",
+			p.Gpio, len(sr.bits))
+	}
+	return p.Gpio, nil
+}
+
+// Outputs need no export.
+func (sr *ShiftRegister) Export(p *Pin) error {
+	_, err := sr.line(p)
+	return err
+}
+
+func (sr *ShiftRegister) Unexport(p *Pin) error { return nil }
+
+func (sr *ShiftRegister) IsExported(p *Pin) bool {
+	_, err := sr.line(p)
+	return err == nil
+}
+
+func (sr *ShiftRegister) Direction(p *Pin) (string, error) {
+	_, err := sr.line(p)
+	return "out", err
+}
+
+// SetDirection takes "high" and "low" to also set the output, and "out".
+func (sr *ShiftRegister) SetDirection(p *Pin, dir string) error {
+	switch dir {
+	case "out":
+		_, err := sr.line(p)
+		return err
+	case "high", "low":
+		return sr.Write(p, dir == "high")
+	}
+	return fmt.Errorf("shift register outputs can't be %s", dir)
+}
+
+func (sr *ShiftRegister) Read(p *Pin) (bool, error) {
+	i, err := sr.line(p)
+	if err != nil {
+		return false, err
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.bits[i] != p.ActiveLow, nil
+}
+
+func (sr *ShiftRegister) Write(p *Pin, v bool) error {
+	i, err := sr.line(p)
+	if err != nil {
+		return err
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.bits[i] = v != p.ActiveLow
+	return sr.shift()
+}
+
+func (sr *ShiftRegister) Watch(p *Pin, edge Edge) (<-chan Event, error) {
+	if edge == EdgeNone {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("shift register outputs have no edges")
+}
+
+func (sr *ShiftRegister) openGroup(pins []*Pin) (lineGroup, error) {
+	lines := make([]int, len(pins))
+	for i, p := range pins {
+		l, err := sr.line(p)
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = l
+	}
+	return &shiftGroup{sr, pins, lines}, nil
+}
+
+// shiftGroup is a PinGroup of a ShiftRegister's outputs.
+type shiftGroup struct {
+	sr    *ShiftRegister
+	pins  []*Pin
+	lines []int
+}
+
+func (g *shiftGroup) read() (bits uint64, err error) {
+	g.sr.mu.Lock()
+	defer g.sr.mu.Unlock()
+	for i, l := range g.lines {
+		if g.sr.bits[l] != g.pins[i].ActiveLow {
+			bits |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+func (g *shiftGroup) write(bits, mask uint64) error {
+	g.sr.mu.Lock()
+	defer g.sr.mu.Unlock()
+	for i, l := range g.lines {
+		if mask&(1<<uint(i)) != 0 {
+			g.sr.bits[l] = (bits&(1<<uint(i)) != 0) != g.pins[i].ActiveLow
+		}
+	}
+	return g.sr.shift()
+}
+
+func (g *shiftGroup) setDirection(dir string) error {
+	switch dir {
+	case "out":
+		return nil
+	case "high", "low":
+		bits := uint64(0)
+		if dir == "high" {
+			bits = ^bits
+		}
+		return g.write(bits, ^uint64(0))
+	}
+	return fmt.Errorf("shift register outputs can't be %s", dir)
+}
+
+func (g *shiftGroup) close() error { return nil }
@@ -0,0 +1,166 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrSimUnavailable is returned by CreateSimChip if neither the kernel's
+// gpio-sim configfs interface is present, i.e. configfs isn't mounted or the
+// gpio-sim module isn't loaded, nor can the gpio-mockup module be loaded.
+var ErrSimUnavailable = errors.New("neither gpio-sim nor gpio-mockup available")
+
+// Label of the controller of the gpio-mockup module, which can't be named.
+const mockupLabel = "gpio-mockup-A"
+
+// SimChip is a virtual gpio controller made by the kernel's gpio-sim module
+// or, on kernels without it, gpio-mockup.
+type SimChip struct {
+	// Label of the simulated bank, also the name of its configfs group;
+	// gpio-mockup-A of gpio-mockup.
+	Label    string
+	NumLines int
+	// Name of the gpio-sim platform device, e.g. "gpio-sim.0".
+	DevName string
+	// Name of the resulting controller, e.g. "gpiochip2".
+	ChipName string
+
+	dir string
+	// Whether the chip is of gpio-mockup rather than gpio-sim.
+	mockup bool
+}
+
+func simDir() string {
+	return prefix + "/sys/kernel/config/gpio-sim"
+}
+
+// SimAvailable reports whether CreateSimChip can be expected to work.
+func SimAvailable() bool {
+	fi, err := os.Stat(simDir())
+	return err == nil && fi.IsDir() || mockupAvailable()
+}
+
+// mockupAvailable reports whether gpio-mockup can be loaded for a chip: it
+// makes its chips as the module is loaded, so it mustn't be already, and
+// the system's modules are only of use without a debug prefix.
+func mockupAvailable() bool {
+	if prefix != "" {
+		return false
+	}
+	if _, err := os.Stat("/sys/module/gpio_mockup"); err == nil {
+		return false
+	}
+	return exec.Command("modprobe", "-n", "-q", "gpio-mockup").Run() == nil
+}
+
+// CreateSimChip makes and activates a gpio-sim device with a single bank of
+// numLines lines. Without gpio-sim it loads gpio-mockup for a chip of those
+// lines instead, labeled gpio-mockup-A whatever the label, so there can be
+// only one at a time. The caller should Remove it when done.
+func CreateSimChip(label string, numLines int) (s *SimChip, err error) {
+	if fi, e := os.Stat(simDir()); e != nil || !fi.IsDir() {
+		if mockupAvailable() {
+			return createMockupChip(numLines)
+		}
+		return nil, ErrSimUnavailable
+	}
+	s = &SimChip{
+		Label:    label,
+		NumLines: numLines,
+		dir:      filepath.Join(simDir(), label),
+	}
+	if err = os.Mkdir(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			s.Remove()
+			s = nil
+		}
+	}()
+	bank := filepath.Join(s.dir, "bank0")
+	if err = os.Mkdir(bank, 0755); err != nil {
+		return
+	}
+	if err = writeAttr(bank, "num_lines", fmt.Sprint(numLines)); err != nil {
+		return
+	}
+	if err = writeAttr(bank, "label", label); err != nil {
+		return
+	}
+	if err = writeAttr(s.dir, "live", "1"); err != nil {
+		return
+	}
+	s.DevName = readAttr(s.dir, "dev_name")
+	s.ChipName = readAttr(bank, "chip_name")
+	return
+}
+
+func createMockupChip(numLines int) (*SimChip, error) {
+	if numLines <= 0 {
+		return nil, fmt.Errorf("invalid number of lines %d", numLines)
+	}
+	out, err := exec.Command("modprobe", "gpio-mockup",
+		fmt.Sprintf("gpio_mockup_ranges=-1,%d", numLines)).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("modprobe gpio-mockup: %v: %s", err,
+			strings.TrimSpace(string(out)))
+	}
+	s := &SimChip{Label: mockupLabel, NumLines: numLines, mockup: true}
+	if c := s.Chip(); c != nil {
+		s.DevName = "gpio-mockup.0"
+		s.ChipName = c.Dev
+	}
+	return s, nil
+}
+
+// Chip returns the sysfs view of the simulated controller, if any.
+func (s *SimChip) Chip() *Chip {
+	for _, c := range Chips() {
+		if c.Label == s.Label {
+			return c
+		}
+	}
+	return nil
+}
+
+// Remove deactivates the device and deletes its configfs groups or, of
+// gpio-mockup, unloads the module.
+func (s *SimChip) Remove() error {
+	if s.mockup {
+		out, err := exec.Command("modprobe", "-r",
+			"gpio-mockup").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("modprobe -r gpio-mockup: %v: %s", err,
+				strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+	if readAttr(s.dir, "live") == "1" {
+		if err := writeAttr(s.dir, "live", "0"); err != nil {
+			return err
+		}
+	}
+	bank := filepath.Join(s.dir, "bank0")
+	if err := os.Remove(bank); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(s.dir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func writeAttr(dir, name, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, name),
+		[]byte(strings.TrimSpace(value)+"\n"), 0644)
+}
@@ -0,0 +1,65 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/platinasystems/gpio"
+)
+
+func TestCreateSimChip(t *testing.T) {
+	if !gpio.SimAvailable() {
+		t.Skip("neither gpio-sim nor gpio-mockup available")
+	}
+	s, err := gpio.CreateSimChip("gpio-test", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := s.Remove(); err != nil {
+			t.Error(err)
+		}
+		if c := s.Chip(); c != nil {
+			t.Errorf("%s still there after Remove", c)
+		}
+	}()
+	c := s.Chip()
+	if c == nil {
+		t.Fatalf("%s: no chip", s.Label)
+	}
+	if c.Ngpio != 8 || s.ChipName == "" {
+		t.Errorf("%s: %d lines, chip name %q", c, c.Ngpio, s.ChipName)
+	}
+	p := &gpio.Pin{Gpio: c.Base + 3, Name: "sim3"}
+	if err = p.Export(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Unexport()
+	if err = p.SetDirection("high"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := p.Value(); err != nil || !v {
+		t.Errorf("%s: high output reads %v, %v", p, v, err)
+	}
+}
+
+func TestCreateSimChipUnavailable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gpio.SetDebugPrefix(dir)
+	defer gpio.SetDebugPrefix("")
+	if gpio.SimAvailable() {
+		t.Error("available without configfs")
+	}
+	if _, err = gpio.CreateSimChip("gpio-test", 8); err != gpio.ErrSimUnavailable {
+		t.Errorf("err %v, want %v", err, gpio.ErrSimUnavailable)
+	}
+}
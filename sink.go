@@ -0,0 +1,113 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// EventSink receives the pin events that ForwardEvents passes on, e.g. to a
+// message bus; see the mqtt package.
+type EventSink interface {
+	Send(e Event) error
+}
+
+// EventSinkFunc is an EventSink of a function, e.g. a webhook post.
+type EventSinkFunc func(e Event) error
+
+func (f EventSinkFunc) Send(e Event) error { return f(e) }
+
+// ForwardEvents sends each event of c to every sink, in turn, until c is
+// closed. The failure of a sink doesn't stop the others; it's given to
+// onError, when that's not nil, with the event.
+//
+//	m, _ := NewEventMonitor()
+//	m.Add(psuFail, EdgeBoth)
+//	go ForwardEvents(m.Events(), logError, publisher)
+func ForwardEvents(c <-chan Event, onError func(Event, error),
+	sinks ...EventSink) {
+	for e := range c {
+		for _, s := range sinks {
+			if err := s.Send(e); err != nil && onError != nil {
+				onError(e, err)
+			}
+		}
+	}
+}
+
+// MarshalJSON gives the pin's name and gpio with the edge, e.g.
+//
+//	{"name":"psu0_fail","gpio":33,"value":true,"rising":true,
+//	 "time":"2016-05-04T10:06:47.116917001Z"}
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name   string    `json:"name"`
+		Gpio   int       `json:"gpio"`
+		Value  bool      `json:"value"`
+		Rising bool      `json:"rising"`
+		Time   time.Time `json:"time"`
+	}{e.Pin.Name, e.Pin.Gpio, e.Value, e.Rising, e.Time})
+}
+
+// StreamTo writes each of the monitor's events to out, as a line of the
+// event's JSON, format "json", or a CSV record of time, name, gpio, value
+// and rising after a header, format "csv", until ctx is done or the monitor
+// is closed, when it returns nil. Each event is flushed, if out has a Flush
+// method, e.g. a bufio.Writer, so that a tail of the file sees it. A write
+// error stops the stream and is returned.
+//
+//	m.StreamTo(ctx, os.Stdout, "csv")
+func (m *EventMonitor) StreamTo(ctx context.Context, out io.Writer, format string) error {
+	var write func(e Event) error
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		write = func(e Event) error { return enc.Encode(e) }
+	case "csv":
+		w := csv.NewWriter(out)
+		write = func(e Event) error {
+			w.Write([]string{e.Time.Format(time.RFC3339Nano), e.Pin.Name,
+				strconv.Itoa(e.Pin.Gpio), strconv.FormatBool(e.Value),
+				strconv.FormatBool(e.Rising)})
+			w.Flush()
+			return w.Error()
+		}
+		w.Write([]string{"time", "name", "gpio", "value", "rising"})
+		if w.Flush(); w.Error() != nil {
+			return w.Error()
+		}
+	default:
+		return fmt.Errorf("unknown event format %q", format)
+	}
+	flush := func() error { return nil }
+	if f, ok := out.(interface{ Flush() error }); ok {
+		flush = f.Flush
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-m.Events():
+			if !ok {
+				return nil
+			}
+			if err := write(e); err != nil {
+				return err
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
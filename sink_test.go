@@ -0,0 +1,159 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+// flushBuffer is a buffer counting its flushes, failing writes after max
+// bytes if max isn't 0.
+type flushBuffer struct {
+	mu      sync.Mutex
+	b       bytes.Buffer
+	flushes int
+	max     int
+}
+
+var errFull = errors.New("buffer full")
+
+func (w *flushBuffer) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.max != 0 && w.b.Len()+len(b) > w.max {
+		return 0, errFull
+	}
+	return w.b.Write(b)
+}
+
+func (w *flushBuffer) Flush() error {
+	w.mu.Lock()
+	w.flushes++
+	w.mu.Unlock()
+	return nil
+}
+
+// lines waits for the buffer to have n lines and returns them.
+func (w *flushBuffer) lines(t *testing.T, n int) []string {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); ; {
+		w.mu.Lock()
+		l := strings.Split(strings.TrimSuffix(w.b.String(), "\n"), "\n")
+		flushes := w.flushes
+		w.mu.Unlock()
+		if len(l) >= n && flushes >= n {
+			return l
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d lines: %q", len(l), n, l)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stream streams the edges of an input of a gpiotest backend to w in the
+// format, returning the backend, the stream's error once it's ended and a
+// function ending it.
+func stream(t *testing.T, w *flushBuffer, format string) (*gpiotest.Backend, <-chan error, func()) {
+	be := gpiotest.New()
+	m, err := gpio.NewEventMonitor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	p := &gpio.Pin{Gpio: 4, Name: "psu_fail", Backend: be}
+	if err = m.Add(p, gpio.EdgeBoth); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() { done <- m.StreamTo(ctx, w, format) }()
+	return be, done, cancel
+}
+
+func TestStreamToJSON(t *testing.T) {
+	w := new(flushBuffer)
+	be, done, cancel := stream(t, w, "json")
+	be.Inject(4, true)
+	be.Inject(4, false)
+	l := w.lines(t, 2)
+	for i, want := range []bool{true, false} {
+		var e struct {
+			Name          string
+			Gpio          int
+			Value, Rising bool
+		}
+		if err := json.Unmarshal([]byte(l[i]), &e); err != nil {
+			t.Fatalf("%q: %v", l[i], err)
+		}
+		if e.Name != "psu_fail" || e.Gpio != 4 || e.Value != want ||
+			e.Rising != want {
+			t.Errorf("line %d %q", i, l[i])
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("StreamTo: %v", err)
+	}
+}
+
+func TestStreamToCSV(t *testing.T) {
+	w := new(flushBuffer)
+	be, done, cancel := stream(t, w, "csv")
+	be.Inject(4, true)
+	l := w.lines(t, 2)
+	if l[0] != "time,name,gpio,value,rising" {
+		t.Errorf("header %q", l[0])
+	}
+	f := strings.Split(l[1], ",")
+	if len(f) != 5 || f[1] != "psu_fail" || f[2] != "4" ||
+		f[3] != "true" || f[4] != "true" {
+		t.Errorf("record %q", l[1])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, f[0]); err != nil {
+		t.Errorf("record time: %v", err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("StreamTo: %v", err)
+	}
+}
+
+func TestStreamToWriteError(t *testing.T) {
+	w := &flushBuffer{max: 1}
+	be, done, _ := stream(t, w, "json")
+	be.Inject(4, true)
+	select {
+	case err := <-done:
+		if !errors.Is(err, errFull) {
+			t.Errorf("StreamTo: %v, want %v", err, errFull)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StreamTo didn't stop on a write error")
+	}
+}
+
+func TestStreamToFormat(t *testing.T) {
+	m, err := gpio.NewEventMonitor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	err = m.StreamTo(context.Background(), new(bytes.Buffer), "xml")
+	if err == nil {
+		t.Error("unknown format didn't fail")
+	}
+}
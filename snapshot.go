@@ -0,0 +1,110 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+// PinState is the state of a pin when snapshotted.
+type PinState struct {
+	Pin       *Pin
+	Direction string
+	Value     bool
+	ActiveLow bool
+}
+
+// PinSnapshot is the state of a set of pins, for Restore.
+type PinSnapshot []PinState
+
+// Snapshot records the direction and value of the given pins or, given
+// none, of every pin, in name order, e.g. before handing them to a firmware
+// programmer. Pins that can't be read are left out and reported in a
+// PinErrors.
+func Snapshot(pins ...*Pin) (s PinSnapshot, err error) {
+	if len(pins) == 0 {
+		pins = SortedPins()
+	}
+	errs := make(PinErrors)
+	for _, p := range pins {
+		st := PinState{Pin: p}
+		if st.Direction, err = p.Direction(); err == nil {
+			st.Value, err = p.Value()
+		}
+		if err != nil {
+			errs[p] = err
+			continue
+		}
+		st.ActiveLow = p.ActiveLow
+		s = append(s, st)
+	}
+	err = nil
+	if len(errs) != 0 {
+		err = errs
+	}
+	return
+}
+
+// Restore puts the pins back as snapshotted; outputs are set to their value
+// as they're switched to output so they don't glitch. The pins that can't
+// be restored are reported in a PinErrors.
+func Restore(s PinSnapshot) error {
+	errs := make(PinErrors)
+	for _, st := range s {
+		p := st.Pin
+		if st.ActiveLow != p.ActiveLow {
+			if err := p.SetActiveLow(st.ActiveLow); err != nil {
+				errs[p] = err
+				continue
+			}
+		}
+		dir := st.Direction
+		if dir == "out" {
+			dir = "low"
+			if st.Value {
+				dir = "high"
+			}
+		}
+		if err := p.SetDirection(dir); err != nil {
+			errs[p] = err
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// Reapply puts the configuration this process gave the pin back on its
+// line, e.g. after another process unexported and exported it again: the
+// pin's ActiveLow, Bias, Drive and Debounce, those its backend applies,
+// then the direction it last had and, for an output, the value last
+// written. A watch ends with the release of the line, so its edge is armed
+// again by Watch.
+func (p *Pin) Reapply() error {
+	mu.Lock()
+	dir, v, written := p.dir, p.wrote, p.written
+	mu.Unlock()
+	if err := p.SetActiveLow(p.ActiveLow); err != nil {
+		return err
+	}
+	if p.Bias != BiasAsIs {
+		if err := p.SetBias(p.Bias); err != nil {
+			return err
+		}
+	}
+	if err := p.SetDrive(p.Drive); err != nil {
+		return err
+	}
+	if err := p.SetDebounce(p.Debounce); err != nil {
+		return err
+	}
+	switch {
+	case dir == "out" && written:
+		dir = "low"
+		if v {
+			dir = "high"
+		}
+	case dir == "":
+		return nil
+	}
+	return p.SetDirection(dir)
+}
@@ -0,0 +1,197 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// SoftPWM bit-bangs a waveform of the given frequency (Hz) and duty cycle
+// (0 through 1) on the pin until ctx is cancelled, leaving the pin driven
+// low. It returns nil on cancellation or the first write error.
+//
+// Timing is at the mercy of the scheduler and sysfs latency, so expect
+// jitter of tens of microseconds or worse and an upper frequency in the low
+// kHz; use a hardware PWM where accuracy matters.
+func (p *Pin) SoftPWM(ctx context.Context, freq float64, duty float64) error {
+	s, err := NewSoftPWM(p, freq, duty)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+	case <-s.done:
+	}
+	return s.Stop()
+}
+
+// SoftPWM is a software PWM on an output pin, driven by a goroutine of its
+// own, whose frequency and duty cycle can change while it runs; see
+// Pin.SoftPWM for the limits of its timing.
+type SoftPWM struct {
+	Pin *Pin
+
+	mu     sync.Mutex
+	period time.Duration
+	high   time.Duration
+	jitter PWMJitter
+	sum    time.Duration
+	err    error
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// PWMJitter is how late a SoftPWM's edges have been.
+type PWMJitter struct {
+	Edges int
+	Mean  time.Duration
+	Max   time.Duration
+}
+
+// NewSoftPWM drives the pin low then starts the waveform.
+func NewSoftPWM(p *Pin, freq, duty float64) (*SoftPWM, error) {
+	s := &SoftPWM{Pin: p, stop: make(chan struct{}),
+		done: make(chan struct{})}
+	if err := s.set(freq, duty); err != nil {
+		return nil, err
+	}
+	if err := p.SetDirection("low"); err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+// set validates and sets the period and high time; a period under a
+// microsecond is refused as beyond any software PWM.
+func (s *SoftPWM) set(freq, duty float64) error {
+	if math.IsNaN(freq) || math.IsInf(freq, 0) || freq <= 0 {
+		return fmt.Errorf("%s: invalid pwm frequency %g", s.Pin, freq)
+	}
+	if math.IsNaN(duty) || duty < 0 || duty > 1 {
+		return fmt.Errorf("%s: invalid pwm duty cycle %g", s.Pin, duty)
+	}
+	period := time.Duration(float64(time.Second) / freq)
+	if period < time.Microsecond {
+		return fmt.Errorf("%s: pwm frequency %g above 1MHz", s.Pin, freq)
+	}
+	s.mu.Lock()
+	s.period = period
+	s.high = time.Duration(float64(period) * duty)
+	s.mu.Unlock()
+	return nil
+}
+
+// SetDuty changes the duty cycle from the next period.
+func (s *SoftPWM) SetDuty(duty float64) error {
+	s.mu.Lock()
+	freq := float64(time.Second) / float64(s.period)
+	s.mu.Unlock()
+	return s.set(freq, duty)
+}
+
+// SetDutyCycle is SetDuty, for FanOutput.
+func (s *SoftPWM) SetDutyCycle(duty float64) error { return s.SetDuty(duty) }
+
+// SetFrequency changes the frequency from the next period, keeping the
+// duty cycle.
+func (s *SoftPWM) SetFrequency(freq float64) error {
+	s.mu.Lock()
+	duty := float64(s.high) / float64(s.period)
+	s.mu.Unlock()
+	return s.set(freq, duty)
+}
+
+// Jitter returns how late the edges have been so far.
+func (s *SoftPWM) Jitter() PWMJitter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jitter
+}
+
+// Stop ends the waveform, leaving the pin driven low, and returns the write
+// error that ended it early, if any.
+func (s *SoftPWM) Stop() error {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *SoftPWM) run() {
+	var err error
+	defer func() {
+		if e := s.Pin.SetValue(false); err == nil {
+			err = e
+		}
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		close(s.done)
+	}()
+	t := time.NewTimer(0)
+	defer t.Stop()
+	<-t.C
+	// until waits for the deadline, noting how late it wakes.
+	until := func(deadline time.Time) bool {
+		t.Reset(time.Until(deadline))
+		select {
+		case <-s.stop:
+			return false
+		case <-t.C:
+		}
+		late := time.Since(deadline)
+		s.mu.Lock()
+		s.jitter.Edges++
+		s.sum += late
+		s.jitter.Mean = s.sum / time.Duration(s.jitter.Edges)
+		if late > s.jitter.Max {
+			s.jitter.Max = late
+		}
+		s.mu.Unlock()
+		return true
+	}
+	level := false
+	set := func(v bool) bool {
+		if v != level {
+			if err = s.Pin.SetValue(v); err != nil {
+				return false
+			}
+			level = v
+		}
+		return true
+	}
+	start := time.Now()
+	for {
+		s.mu.Lock()
+		period, high := s.period, s.high
+		s.mu.Unlock()
+		if high > 0 {
+			if !set(true) || !until(start.Add(high)) {
+				return
+			}
+		}
+		if high < period {
+			if !set(false) || !until(start.Add(period)) {
+				return
+			}
+		}
+		// Drop the periods missed rather than rush to catch up.
+		if start = start.Add(period); time.Since(start) > period {
+			start = time.Now()
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/gpio"
+	"github.com/platinasystems/gpio/gpiotest"
+)
+
+func TestSoftPWM(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 1, Name: "led", Backend: be}
+	c, err := be.Watch(p, gpio.EdgeBoth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := gpio.NewSoftPWM(p, 1000, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Edges of a few periods, high then low.
+	for i := 0; i < 6; i++ {
+		select {
+		case e := <-c:
+			if e.Value != (i%2 == 0) {
+				t.Fatalf("edge %d value %v", i, e.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %d edges", i)
+		}
+	}
+	if err = s.SetDuty(0.25); err != nil {
+		t.Error(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Stop() }()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop didn't return")
+	}
+	if be.Level(1) {
+		t.Error("pin high after Stop")
+	}
+	if j := s.Jitter(); j.Edges == 0 {
+		t.Error("no edges timed")
+	}
+}
+
+func TestSoftPWMInvalid(t *testing.T) {
+	be := gpiotest.New()
+	p := &gpio.Pin{Gpio: 1, Name: "led", Backend: be}
+	for _, tt := range []struct{ freq, duty float64 }{
+		{0, 0.5},
+		{-1, 0.5},
+		{math.NaN(), 0.5},
+		{math.Inf(1), 0.5},
+		{2e6, 0.5},
+		{2e9, 0.5},
+		{1000, math.NaN()},
+		{1000, -0.1},
+		{1000, 1.1},
+	} {
+		if s, err := gpio.NewSoftPWM(p, tt.freq, tt.duty); err == nil {
+			s.Stop()
+			t.Errorf("freq %g duty %g: no error", tt.freq, tt.duty)
+		}
+	}
+	if ops := be.Ops(); len(ops) != 0 {
+		t.Errorf("invalid pwm drove the pin: %v", ops)
+	}
+	s, err := gpio.NewSoftPWM(p, 1000, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if err = s.SetDuty(math.NaN()); err == nil {
+		t.Error("SetDuty NaN: no error")
+	}
+	if err = s.SetFrequency(math.Inf(1)); err == nil {
+		t.Error("SetFrequency Inf: no error")
+	}
+	if err = s.SetFrequency(1e9); err == nil {
+		t.Error("SetFrequency 1GHz: no error")
+	}
+}
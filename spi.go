@@ -0,0 +1,124 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"time"
+)
+
+// SPIMaster bit-bangs an SPI bus, most significant bit first.
+//
+// Every clock edge is a sysfs write so the bus runs at a few kHz at best;
+// it's meant for the odd shift register or ID prom on pins that don't reach
+// an SPI controller, not bulk transfers.
+type SPIMaster struct {
+	CLK, MOSI, MISO, CS *Pin
+	// SPI mode 0 through 3; bit 1 is CPOL and bit 0 CPHA.
+	Mode int
+	// Minimum time between clock edges.
+	HalfPeriod time.Duration
+}
+
+// NewSPIMaster idles the bus, with CS high and CLK at its mode's polarity,
+// and checks that the pins actually took their directions. MISO may be nil
+// for a write only bus, in which case Transfer reads zeros.
+func NewSPIMaster(clk, mosi, miso, cs *Pin, mode int) (*SPIMaster, error) {
+	if mode < 0 || mode > 3 {
+		return nil, fmt.Errorf("invalid spi mode %d", mode)
+	}
+	s := &SPIMaster{CLK: clk, MOSI: mosi, MISO: miso, CS: cs, Mode: mode}
+	idle := "low"
+	if s.cpol() {
+		idle = "high"
+	}
+	want := map[*Pin]string{cs: "high", clk: idle, mosi: "low"}
+	if miso != nil {
+		want[miso] = "in"
+	}
+	for p, dir := range want {
+		if err := p.SetDirection(dir); err != nil {
+			return nil, err
+		}
+		if dir != "in" {
+			dir = "out"
+		}
+		if got, err := p.Direction(); err != nil {
+			return nil, err
+		} else if got != dir {
+			return nil, fmt.Errorf("%s: direction is %s not %s",
+				p, got, dir)
+		}
+	}
+	return s, nil
+}
+
+func (s *SPIMaster) cpol() bool { return s.Mode&2 != 0 }
+func (s *SPIMaster) cpha() bool { return s.Mode&1 != 0 }
+
+// Transfer asserts CS, shifts out write while shifting in as many bytes,
+// then releases CS.
+func (s *SPIMaster) Transfer(write []byte) (read []byte, err error) {
+	if err = s.CS.SetValue(false); err != nil {
+		return
+	}
+	defer func() {
+		if e := s.CS.SetValue(true); err == nil {
+			err = e
+		}
+	}()
+	read = make([]byte, len(write))
+	for i, b := range write {
+		for bit := 7; bit >= 0; bit-- {
+			in, e := s.bit(b&(1<<uint(bit)) != 0)
+			if e != nil {
+				return nil, e
+			}
+			if in {
+				read[i] |= 1 << uint(bit)
+			}
+		}
+	}
+	return
+}
+
+// bit clocks out one bit and returns the one clocked in.
+func (s *SPIMaster) bit(out bool) (in bool, err error) {
+	idle := s.cpol()
+	if !s.cpha() {
+		if err = s.MOSI.SetValue(out); err != nil {
+			return
+		}
+		time.Sleep(s.HalfPeriod)
+	}
+	if err = s.CLK.SetValue(!idle); err != nil {
+		return
+	}
+	if s.cpha() {
+		if err = s.MOSI.SetValue(out); err != nil {
+			return
+		}
+	} else if in, err = s.sample(); err != nil {
+		return
+	}
+	time.Sleep(s.HalfPeriod)
+	if err = s.CLK.SetValue(idle); err != nil {
+		return
+	}
+	if s.cpha() {
+		if in, err = s.sample(); err != nil {
+			return
+		}
+		time.Sleep(s.HalfPeriod)
+	}
+	return
+}
+
+func (s *SPIMaster) sample() (bool, error) {
+	if s.MISO == nil {
+		return false, nil
+	}
+	return s.MISO.Value()
+}
@@ -0,0 +1,109 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package spibb is an SPI master bit-banged over gpio pins, for shift
+// registers, ADCs and flash chips hanging off spare gpios.
+//
+//	c, err := spibb.NewByName("spi_clk", "spi_mosi", "spi_miso", "spi_cs",
+//		0, 100000)
+//	r := make([]byte, 4)
+//	err = c.Tx([]byte{0x9f, 0, 0, 0}, r)
+//
+// It's a thin, locked wrapper of gpio.SPIMaster; see that for its limits.
+package spibb
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/gpio"
+)
+
+// Conn is the full duplex transfer interface shared with the common Go SPI
+// libraries.
+type Conn interface {
+	Tx(w, r []byte) error
+	String() string
+}
+
+// Master is a Conn on CLK, MOSI, MISO and CS pins. It's safe for
+// concurrent use; transfers take turns.
+type Master struct {
+	mu sync.Mutex
+	m  *gpio.SPIMaster
+}
+
+var _ Conn = (*Master)(nil)
+
+// New idles the bus in the given mode, 0 through 3, with a clock of at
+// most hz, zero for as fast as the pins go. MISO may be nil for a write
+// only bus.
+func New(clk, mosi, miso, cs *gpio.Pin, mode int, hz int64) (*Master, error) {
+	m, err := gpio.NewSPIMaster(clk, mosi, miso, cs, mode)
+	if err != nil {
+		return nil, err
+	}
+	c := &Master{m: m}
+	if err = c.SetSpeed(hz); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewByName is New of the pins gpio.FindPin finds by name; an empty MISO
+// name is no MISO.
+func NewByName(clk, mosi, miso, cs string, mode int, hz int64) (*Master, error) {
+	var pins [4]*gpio.Pin
+	for i, name := range []string{clk, mosi, miso, cs} {
+		if name == "" && i == 2 {
+			continue
+		}
+		p, f := gpio.FindPin(name)
+		if !f {
+			return nil, fmt.Errorf("%s: %w", name, gpio.ErrNoSuchPin)
+		}
+		pins[i] = p
+	}
+	return New(pins[0], pins[1], pins[2], pins[3], mode, hz)
+}
+
+func (c *Master) String() string {
+	return fmt.Sprintf("spibb(%s) mode %d", c.m.CS, c.m.Mode)
+}
+
+// Tx asserts CS, shifts out w while shifting in r, padding the shorter with
+// zeros, then releases CS.
+func (c *Master) Tx(w, r []byte) error {
+	n := len(w)
+	if len(r) > n {
+		n = len(r)
+	}
+	out := make([]byte, n)
+	copy(out, w)
+	c.mu.Lock()
+	in, err := c.m.Transfer(out)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	copy(r, in)
+	return nil
+}
+
+// SetSpeed sets the clock rate to at most hz, a hint since the pins'
+// latency may well keep it slower. Zero runs as fast as the pins go.
+func (c *Master) SetSpeed(hz int64) error {
+	if hz < 0 {
+		return fmt.Errorf("%s: invalid speed %d", c, hz)
+	}
+	var half time.Duration
+	if hz > 0 {
+		half = time.Second / time.Duration(2*hz)
+	}
+	c.mu.Lock()
+	c.m.HalfPeriod = half
+	c.mu.Unlock()
+	return nil
+}
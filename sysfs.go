@@ -0,0 +1,396 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type sysfsBackend struct{}
+
+func (b sysfsBackend) Export(p *Pin) (err error) {
+	fn := prefix + "/sys/class/gpio/export"
+	f, err := os.OpenFile(fn, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	_, err = fmt.Fprintf(f, "%d\n", p.Gpio)
+	f.Close()
+	if err != nil {
+		return
+	}
+	// Udev may yet be creating the attributes or setting their
+	// permissions; Open waits for them.
+	mu.Lock()
+	p.exportedAt = time.Now()
+	timeout, gid := exportTimeout, exportGID
+	mu.Unlock()
+	if f, _, err = p.Open("value"); err != nil {
+		return fmt.Errorf("attributes not ready %v after export: %w",
+			timeout, err)
+	}
+	f.Close()
+	if gid >= 0 && os.Geteuid() == 0 {
+		if err = sysfsChgrp(p, gid); err != nil {
+			return
+		}
+	}
+	if p.ActiveLow {
+		err = b.setActiveLow(p)
+	}
+	return
+}
+
+// sysfsChgrp gives the group read and write permission of the pin's
+// attributes; those the line lacks, e.g. edge without an interrupt, are
+// skipped.
+func sysfsChgrp(p *Pin, gid int) error {
+	for _, name := range []string{"value", "direction", "edge",
+		"active_low"} {
+		fn := fmt.Sprintf(prefix+"/sys/class/gpio/gpio%d/%s", p.Gpio,
+			name)
+		fi, err := os.Stat(fn)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			err = os.Chown(fn, -1, gid)
+		}
+		if err == nil {
+			err = os.Chmod(fn, fi.Mode().Perm()|0060)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sysfsBackend) Unexport(p *Pin) (err error) {
+	fn := prefix + "/sys/class/gpio/unexport"
+	f, err := os.OpenFile(fn, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%d\n", p.Gpio)
+	return
+}
+
+// The kernel inverts values and edges of pins with active_low set.
+func (b sysfsBackend) setActiveLow(p *Pin) (err error) {
+	if !b.IsExported(p) {
+		return
+	}
+	f, _, err := p.Open("active_low")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	x := 0
+	if p.ActiveLow {
+		x = 1
+	}
+	_, err = fmt.Fprintf(f, "%d\n", x)
+	return
+}
+
+func (sysfsBackend) edge(p *Pin) (edge string, err error) {
+	f, _, err := p.Open("edge")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, err = fmt.Fscanf(f, "%s\n", &edge)
+	return
+}
+
+func (sysfsBackend) activeLow(p *Pin) (on bool, err error) {
+	f, _, err := p.Open("active_low")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	x := 0
+	_, err = fmt.Fscanf(f, "%d\n", &x)
+	on = x != 0
+	return
+}
+
+func (sysfsBackend) IsExported(p *Pin) bool {
+	fn := fmt.Sprintf(prefix+"/sys/class/gpio/gpio%d/value", p.Gpio)
+	_, err := os.Stat(fn)
+	if err != nil {
+		return false
+	}
+	return true
+}
+
+func (sysfsBackend) Direction(p *Pin) (dir string, err error) {
+	f, _, err := p.Open("direction")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = fmt.Fscanf(f, "%s\n", &dir)
+
+	return
+}
+
+func (sysfsBackend) SetDirection(p *Pin, dir string) (err error) {
+	if dir != "in" && p.Drive != DrivePushPull {
+		return sysfsSingleEnded(p, dir == "high")
+	}
+	// Direction levels are the line's, even with active_low.
+	if p.ActiveLow {
+		switch dir {
+		case "out", "low":
+			dir = "high"
+		case "high":
+			dir = "low"
+		}
+	}
+	return sysfsDirection(p, dir)
+}
+
+func sysfsDirection(p *Pin, dir string) (err error) {
+	f, _, err := p.Open("direction")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s\n", dir)
+	return
+}
+
+// sysfsSingleEnded emulates an open drain or source output by switching
+// the pin to input rather than driving the line's released level.
+func sysfsSingleEnded(p *Pin, v bool) error {
+	level, dir := v != p.ActiveLow, "in"
+	switch {
+	case p.Drive == DriveOpenDrain && !level:
+		dir = "low"
+	case p.Drive == DriveOpenSource && level:
+		dir = "high"
+	}
+	return sysfsDirection(p, dir)
+}
+
+func (sysfsBackend) hold(p *Pin) error {
+	mu.Lock()
+	held := p.value != nil
+	mu.Unlock()
+	if held {
+		return nil
+	}
+	f, _, err := p.Open("value")
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	p.value = f
+	mu.Unlock()
+	return nil
+}
+
+func (sysfsBackend) release(p *Pin) (err error) {
+	mu.Lock()
+	f := p.value
+	p.value = nil
+	mu.Unlock()
+	if f != nil {
+		err = f.Close()
+	}
+	return
+}
+
+// held returns the value attribute kept open by Hold, if any.
+func (p *Pin) held() *os.File {
+	mu.Lock()
+	defer mu.Unlock()
+	return p.value
+}
+
+func (sysfsBackend) Write(p *Pin, v bool) (err error) {
+	if p.Drive != DrivePushPull {
+		return sysfsSingleEnded(p, v)
+	}
+	if f := p.held(); f != nil {
+		b := []byte("0\n")
+		if v {
+			b[0] = '1'
+		}
+		_, err = f.WriteAt(b, 0)
+		return
+	}
+	f, _, err := p.Open("value")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	x := 0
+	if v {
+		x = 1
+	}
+	_, err = fmt.Fprintf(f, "%d\n", x)
+	return
+}
+
+func (sysfsBackend) Read(p *Pin) (v bool, err error) {
+	if f := p.held(); f != nil {
+		b := make([]byte, 2)
+		if _, err = f.ReadAt(b, 0); err == io.EOF {
+			err = nil
+		}
+		v = err == nil && b[0] != '0'
+		return
+	}
+	f, _, err := p.Open("value")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	x := 0
+	_, err = fmt.Fscanf(f, "%d\n", &x)
+	if x != 0 {
+		v = true
+	}
+	return
+}
+
+func (b sysfsBackend) Watch(p *Pin, edge Edge) (<-chan Event, error) {
+	return watchArmed(b, p, edge)
+}
+
+func (sysfsBackend) armEdge(p *Pin, edge Edge) (src *edgeSource, err error) {
+	p.stopWatch()
+	f, _, err := p.Open("edge")
+	if err != nil {
+		return
+	}
+	// Debouncing follows the level through both edges.
+	armed := edge
+	if p.Debounce > 0 && edge != EdgeNone {
+		armed = EdgeBoth
+	}
+	_, err = fmt.Fprintf(f, "%s\n", armed)
+	f.Close()
+	if err != nil || edge == EdgeNone {
+		return
+	}
+	// Reading the value clears any stale notification.
+	fn := fmt.Sprintf(prefix+"/sys/class/gpio/gpio%d/value", p.Gpio)
+	if f, err = os.Open(fn); err != nil {
+		return
+	}
+	buf := make([]byte, 2)
+	src = &edgeSource{
+		fd:     int(f.Fd()),
+		events: pollSysfsEdge,
+		read: func() ([]EdgeEvent, error) {
+			_, err := f.ReadAt(buf, 0)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			v := buf[0] != '0'
+			return []EdgeEvent{{
+				Rising: armed.rising(v),
+				Value:  v,
+				Time:   time.Now(),
+			}}, nil
+		},
+		cleanup: func() { f.Close() },
+	}
+	var events []EdgeEvent
+	if events, err = src.read(); err != nil {
+		f.Close()
+		src = nil
+	} else if p.Debounce > 0 {
+		src.db = newDebouncer(p.Debounce, edge, events[0].Value)
+	}
+	return
+}
+
+// sysfsGroup holds the value attributes of a PinGroup's pins open.
+type sysfsGroup struct {
+	pins  []*Pin
+	files []*os.File
+}
+
+func (sysfsBackend) openGroup(pins []*Pin) (lineGroup, error) {
+	g := &sysfsGroup{pins: pins}
+	for _, p := range pins {
+		fn := fmt.Sprintf(prefix+"/sys/class/gpio/gpio%d/value", p.Gpio)
+		f, err := os.OpenFile(fn, os.O_RDWR, 0)
+		if err != nil {
+			g.close()
+			return nil, err
+		}
+		g.files = append(g.files, f)
+	}
+	return g, nil
+}
+
+func (g *sysfsGroup) read() (bits uint64, err error) {
+	buf := make([]byte, 2)
+	for i, f := range g.files {
+		if _, err = f.ReadAt(buf, 0); err != nil && err != io.EOF {
+			return
+		}
+		err = nil
+		if buf[0] != '0' {
+			bits |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+func (g *sysfsGroup) write(bits, mask uint64) error {
+	zero, one := []byte("0\n"), []byte("1\n")
+	for i, f := range g.files {
+		bit := uint64(1) << uint(i)
+		if mask&bit == 0 {
+			continue
+		}
+		if p := g.pins[i]; p.Drive != DrivePushPull {
+			if err := sysfsSingleEnded(p, bits&bit != 0); err != nil {
+				return err
+			}
+			continue
+		}
+		b := zero
+		if bits&bit != 0 {
+			b = one
+		}
+		if _, err := f.WriteAt(b, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *sysfsGroup) setDirection(dir string) error {
+	for _, p := range g.pins {
+		if err := (sysfsBackend{}).SetDirection(p, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *sysfsGroup) close() (err error) {
+	for _, f := range g.files {
+		if e := f.Close(); err == nil {
+			err = e
+		}
+	}
+	g.files = nil
+	return
+}
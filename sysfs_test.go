@@ -0,0 +1,75 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platinasystems/gpio"
+)
+
+// sysfsPin returns an output of the sysfs backend on a fake sysfs tree,
+// held if hold, that's removed at the end of the benchmark.
+func sysfsPin(b *testing.B, hold bool) *gpio.Pin {
+	dir, err := ioutil.TempDir("", "gpio")
+	if err != nil {
+		b.Fatal(err)
+	}
+	gpio.SetDebugPrefix(dir)
+	b.Cleanup(func() {
+		gpio.SetDebugPrefix("")
+		os.RemoveAll(dir)
+	})
+	attrs := filepath.Join(dir, "sys/class/gpio/gpio10")
+	if err = os.MkdirAll(attrs, 0755); err != nil {
+		b.Fatal(err)
+	}
+	for name, v := range map[string]string{"value": "0\n",
+		"direction": "out\n"} {
+		err = ioutil.WriteFile(filepath.Join(attrs, name), []byte(v),
+			0644)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	p := &gpio.Pin{Gpio: 10, Name: "bench", Backend: gpio.Sysfs}
+	if hold {
+		if err = p.Hold(); err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { p.Release() })
+	}
+	return p
+}
+
+func benchmarkRead(b *testing.B, hold bool) {
+	p := sysfsPin(b, hold)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Value(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkWrite(b *testing.B, hold bool) {
+	p := sysfsPin(b, hold)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := p.SetValue(i%2 == 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The held value's pread and pwrite against an open, read or write, and
+// close of each operation.
+func BenchmarkSysfsReadHeld(b *testing.B)  { benchmarkRead(b, true) }
+func BenchmarkSysfsRead(b *testing.B)      { benchmarkRead(b, false) }
+func BenchmarkSysfsWriteHeld(b *testing.B) { benchmarkWrite(b, true) }
+func BenchmarkSysfsWrite(b *testing.B)     { benchmarkWrite(b, false) }
@@ -0,0 +1,106 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CountEdges counts the input's falling edges, the pulses of an open
+// collector tach line, over the window. It replaces any watch of the pin and
+// leaves the pin unwatched.
+func (p *Pin) CountEdges(window time.Duration) (n int, err error) {
+	c, err := p.Watch(EdgeFalling)
+	if err != nil {
+		return
+	}
+	defer p.Watch(EdgeNone)
+	t := time.NewTimer(window)
+	defer t.Stop()
+	for {
+		select {
+		case _, ok := <-c:
+			if !ok {
+				return n, fmt.Errorf("%s: watch ended", p)
+			}
+			n++
+		case <-t.C:
+			return
+		}
+	}
+}
+
+// Tachometer estimates the frequency of an input's falling edges from
+// those of the last Window, e.g. to read the RPM of a fan. It watches the pin
+// until closed.
+type Tachometer struct {
+	Pin          *Pin
+	PulsesPerRev int
+	Window       time.Duration
+
+	mu    sync.Mutex
+	edges []time.Time
+	done  chan struct{}
+}
+
+// NewTachometer starts watching the pin, with a one second window; fans
+// usually give two pulses per revolution.
+func NewTachometer(p *Pin, pulsesPerRev int) (*Tachometer, error) {
+	if pulsesPerRev <= 0 {
+		return nil, fmt.Errorf("%s: invalid pulses per revolution %d",
+			p, pulsesPerRev)
+	}
+	c, err := p.Watch(EdgeFalling)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tachometer{Pin: p, PulsesPerRev: pulsesPerRev,
+		Window: time.Second, done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		for e := range c {
+			t.mu.Lock()
+			t.edges = append(t.prune(e.Time), e.Time)
+			t.mu.Unlock()
+		}
+	}()
+	return t, nil
+}
+
+// prune drops the edges before the window ending at now.
+func (t *Tachometer) prune(now time.Time) []time.Time {
+	i := 0
+	for i < len(t.edges) && now.Sub(t.edges[i]) > t.Window {
+		i++
+	}
+	t.edges = append(t.edges[:0], t.edges[i:]...)
+	return t.edges
+}
+
+// Frequency returns the edges per second, 0 with fewer than two edges in
+// the window.
+func (t *Tachometer) Frequency() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l := t.prune(time.Now())
+	if len(l) < 2 {
+		return 0
+	}
+	return float64(len(l)-1) / l[len(l)-1].Sub(l[0]).Seconds()
+}
+
+// RPM returns the revolutions per minute.
+func (t *Tachometer) RPM() float64 {
+	return t.Frequency() * 60 / float64(t.PulsesPerRev)
+}
+
+// Close stops watching the pin.
+func (t *Tachometer) Close() error {
+	_, err := t.Pin.Watch(EdgeNone)
+	<-t.done
+	return err
+}
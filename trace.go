@@ -0,0 +1,124 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Trace samples the pin at rate Hz until ctx is done and returns the level
+// at the start followed by each transition seen. The first event isn't an
+// edge; its Rising is simply set to match its Value.
+//
+// This is polling, not edge capture: pulses shorter than the sample period
+// are missed and times are only good to a period plus the sysfs read
+// latency, so useful rates top out at a few kHz.
+func (p *Pin) Trace(ctx context.Context, rate float64) (events []EdgeEvent, err error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("%s: invalid trace rate %g", p.Name, rate)
+	}
+	v, err := p.Value()
+	if err != nil {
+		return
+	}
+	events = append(events, EdgeEvent{Rising: v, Value: v, Time: time.Now()})
+	t := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		x, err := p.Value()
+		if err != nil {
+			return events, err
+		}
+		if x != v {
+			events = append(events, newEdgeEvent(v, x, time.Now()))
+			v = x
+		}
+	}
+}
+
+// WriteVCD writes events, as returned by Trace, as a single signal Value
+// Change Dump with nanosecond timescale relative to the first event.
+func WriteVCD(w io.Writer, events []EdgeEvent) error {
+	if len(events) == 0 {
+		return writeVCD(w, []string{"pin"}, nil, time.Time{}, nil)
+	}
+	changes := make([]vcdChange, len(events)-1)
+	for i, e := range events[1:] {
+		changes[i] = vcdChange{0, e.Time, e.Value}
+	}
+	return writeVCD(w, []string{"pin"}, []bool{events[0].Value},
+		events[0].Time, changes)
+}
+
+// vcdChange is a value change of the signal of index i.
+type vcdChange struct {
+	i int
+	t time.Time
+	v bool
+}
+
+// writeVCD dumps the signals, with the initial values, if any, at start and
+// the changes, in time order, after it.
+func writeVCD(w io.Writer, names []string, initial []bool, start time.Time,
+	changes []vcdChange) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "$date %s $end\n", time.Now().Format(time.RFC1123))
+	fmt.Fprint(b, "$timescale 1ns $end\n", "$scope module gpio $end\n")
+	ids := make([]string, len(names))
+	for i, name := range names {
+		ids[i] = vcdID(i)
+		fmt.Fprintf(b, "$var wire 1 %s %s $end\n", ids[i],
+			strings.Join(strings.Fields(name), "_"))
+	}
+	fmt.Fprint(b, "$upscope $end\n", "$enddefinitions $end\n")
+	bit := func(v bool) byte {
+		if v {
+			return '1'
+		}
+		return '0'
+	}
+	if len(initial) > 0 {
+		fmt.Fprint(b, "#0\n$dumpvars\n")
+		for i, v := range initial {
+			fmt.Fprintf(b, "%c%s\n", bit(v), ids[i])
+		}
+		fmt.Fprint(b, "$end\n")
+	}
+	last := time.Duration(-1)
+	for _, c := range changes {
+		d := c.t.Sub(start)
+		if d < 0 {
+			d = 0
+		}
+		if d != last {
+			fmt.Fprintf(b, "#%d\n", d)
+			last = d
+		}
+		fmt.Fprintf(b, "%c%s\n", bit(c.v), ids[c.i])
+	}
+	return b.Flush()
+}
+
+// vcdID returns the identifier code of the signal of index i, in the
+// printable characters ! to ~.
+func vcdID(i int) string {
+	const first, n = '!', '~' - '!' + 1
+	id := []byte{byte(first + i%n)}
+	for i /= n; i > 0; i /= n {
+		i--
+		id = append(id, byte(first+i%n))
+	}
+	return string(id)
+}
@@ -0,0 +1,72 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mismatch is a pin whose live state isn't its Default: Got is "in",
+// "high" or "low", or empty if the pin couldn't be read, with Err why.
+type Mismatch struct {
+	Pin  *Pin
+	Want string
+	Got  string
+	Err  error
+}
+
+func (m Mismatch) String() string {
+	if m.Err != nil {
+		return fmt.Sprintf("%s: want %s: %v", m.Pin.Name, m.Want, m.Err)
+	}
+	return fmt.Sprintf("%s: want %s, got %s", m.Pin.Name, m.Want, m.Got)
+}
+
+// Diff compares the live state of every pin with a Default against it,
+// changing nothing, and returns the mismatches in name order.
+func Diff() (l []Mismatch) {
+	for _, p := range SortedPins() {
+		if p.Default == "" {
+			continue
+		}
+		got, err := p.liveState()
+		if err != nil || got != p.Default {
+			l = append(l, Mismatch{Pin: p, Want: p.Default, Got: got,
+				Err: err})
+		}
+	}
+	return
+}
+
+// Verify is Diff failing with the mismatches.
+func Verify() error {
+	l := Diff()
+	if len(l) == 0 {
+		return nil
+	}
+	s := make([]string, len(l))
+	for i, m := range l {
+		s[i] = m.String()
+	}
+	return fmt.Errorf("%d pins not at their defaults: %s", len(l),
+		strings.Join(s, "; "))
+}
+
+// liveState returns "in", or for outputs "high" or "low".
+func (p *Pin) liveState() (string, error) {
+	dir, err := p.Direction()
+	if err != nil || dir == "in" {
+		return dir, err
+	}
+	v, err := p.Value()
+	if err != nil {
+		return "", err
+	}
+	if v {
+		return "high", nil
+	}
+	return "low", nil
+}
@@ -0,0 +1,135 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Watchdog pets an external watchdog by toggling its pin every Interval.
+// A second goroutine checks on the first and reports when it's missed two
+// intervals, a stall, or failed to write the pin.
+type Watchdog struct {
+	Pin      *Pin
+	Interval time.Duration
+	// OnError, if set, is called with each stall and write error, from
+	// the monitoring goroutine; Err returns the last regardless.
+	OnError func(error)
+
+	mu      sync.Mutex
+	lastPet time.Time
+	err     error
+	stop    chan struct{}
+	done    sync.WaitGroup
+}
+
+// NewWatchdog returns the watchdog of the pin, to be started.
+func NewWatchdog(p *Pin, interval time.Duration) *Watchdog {
+	return &Watchdog{Pin: p, Interval: interval}
+}
+
+// Start drives the pin low and starts petting.
+func (w *Watchdog) Start() error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("%s: invalid watchdog interval %v", w.Pin,
+			w.Interval)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return fmt.Errorf("%s: watchdog already started", w.Pin)
+	}
+	if err := w.Pin.SetDirection("low"); err != nil {
+		return err
+	}
+	w.lastPet, w.err, w.stop = time.Now(), nil, make(chan struct{})
+	w.done.Add(2)
+	go w.pet(w.stop)
+	go w.monitor(w.stop)
+	return nil
+}
+
+// Stop stops petting, leaving the pin as it is; the watchdog will then
+// expire unless it's disabled some other way.
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	stop := w.stop
+	w.stop = nil
+	w.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		w.done.Wait()
+	}
+}
+
+// Kick pets the watchdog now, ahead of the next interval.
+func (w *Watchdog) Kick() error {
+	if err := w.Pin.Toggle(); err != nil {
+		w.fail(err)
+		return err
+	}
+	w.mu.Lock()
+	w.lastPet = time.Now()
+	w.mu.Unlock()
+	return nil
+}
+
+// Err returns the last stall or write error.
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *Watchdog) fail(err error) {
+	w.mu.Lock()
+	w.err = err
+	f := w.OnError
+	w.mu.Unlock()
+	if f != nil {
+		f(err)
+	}
+}
+
+func (w *Watchdog) pet(stop chan struct{}) {
+	defer w.done.Done()
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			w.Kick()
+		}
+	}
+}
+
+func (w *Watchdog) monitor(stop chan struct{}) {
+	defer w.done.Done()
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+	stalled := false
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		w.mu.Lock()
+		since := time.Since(w.lastPet)
+		w.mu.Unlock()
+		// Report a stall once, until petting resumes.
+		if since <= 2*w.Interval {
+			stalled = false
+		} else if !stalled {
+			stalled = true
+			w.fail(fmt.Errorf("%s: watchdog not petted for %v",
+				w.Pin, since.Round(time.Millisecond)))
+		}
+	}
+}